@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func validateAliases(aliases map[string]string) error {
+	for alias, model := range aliases {
+		if alias == "" || model == "" {
+			return fmt.Errorf("invalid aliases entry %q: %q: alias and model must not be empty", alias, model)
+		}
+	}
+	return nil
+}
+
+// resolveModel returns the model an alias stands for, or name itself when
+// it is not an alias.
+func (config Config) resolveModel(name string) string {
+	if model, ok := config.Aliases[name]; ok {
+		return model
+	}
+	return name
+}
+
+// resolveModelAliases replaces the aliases used in the config's model
+// fields with the models they stand for.
+func (config *Config) resolveModelAliases() {
+	config.ChatModel = config.resolveModel(config.ChatModel)
+	config.ToolsModel = config.resolveModel(config.ToolsModel)
+	config.ToolSummary.Model = config.resolveModel(config.ToolSummary.Model)
+	config.ConversationSummary.Model = config.resolveModel(config.ConversationSummary.Model)
+	for i := range config.ModelTiers {
+		config.ModelTiers[i].Model = config.resolveModel(config.ModelTiers[i].Model)
+	}
+}
+
+// modelCommand shows or switches the chat model for this session.
+func (app *App) modelCommand(name string) {
+	if name != "" {
+		model := app.config.resolveModel(name)
+		app.config.ChatModel = model
+		app.config.sources["chat_model"] = sourceSet
+		if model != name {
+			systemColor.Printf("Chat model set to %s (alias %s).\n", model, name)
+		} else {
+			systemColor.Printf("Chat model set to %s.\n", model)
+		}
+		return
+	}
+	systemColor.Printf("Chat model: %s\n", app.config.ChatModel)
+	aliases := make([]string, 0, len(app.config.Aliases))
+	for alias := range app.config.Aliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		systemColor.Printf("  %s -> %s\n", alias, app.config.Aliases[alias])
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import "github.com/parakeet-nest/parakeet/llm"
+
+const (
+	RoleAlternationOff       = "off"
+	RoleAlternationMerge     = "merge"
+	RoleAlternationSeparator = "separator"
+)
+
+// separatorMessages are inserted between two user or two assistant messages
+// by the separator policy, keyed by the role they separate.
+var separatorMessages = map[string]llm.Message{
+	RoleUser:      {Role: RoleAssistant, Content: "Understood."},
+	RoleAssistant: {Role: RoleUser, Content: "Continue."},
+}
+
+// normalizeRoles makes user and assistant messages alternate, as strict
+// backends require, by merging consecutive messages of the same role or
+// separating them with a short message of the other role. System messages
+// are left as they are.
+func normalizeRoles(messages []llm.Message, policy string) []llm.Message {
+	if policy == "" || policy == RoleAlternationOff {
+		return messages
+	}
+	normalized := make([]llm.Message, 0, len(messages))
+	for _, message := range messages {
+		if last := len(normalized) - 1; last >= 0 && message.Role != RoleSystem && normalized[last].Role == message.Role {
+			switch policy {
+			case RoleAlternationMerge:
+				normalized[last].Content += "\n\n" + message.Content
+				continue
+			case RoleAlternationSeparator:
+				normalized = append(normalized, separatorMessages[message.Role])
+			}
+		}
+		normalized = append(normalized, message)
+	}
+	return normalized
+}
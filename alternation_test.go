@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+func TestNormalizeRoles(t *testing.T) {
+	system := llm.Message{Role: RoleSystem, Content: "You are helpful."}
+	question := llm.Message{Role: RoleUser, Content: "What time is it?"}
+	answer := llm.Message{Role: RoleAssistant, Content: "Let me check."}
+	toolNote := llm.Message{Role: RoleAssistant, Content: fmt.Sprintf(toolUsedFormat, "clock")}
+	toolResult := llm.Message{Role: RoleUser, Content: "12:00"}
+	followUp := llm.Message{Role: RoleUser, Content: "And tomorrow?"}
+	// A tool note follows an answer and a user message follows the tool
+	// result, so both pairs break the alternation.
+	toolSequence := []llm.Message{system, question, answer, toolNote, toolResult, followUp}
+
+	tests := []struct {
+		name     string
+		policy   string
+		messages []llm.Message
+		want     []llm.Message
+	}{
+		{
+			name:     "off leaves the messages alone",
+			policy:   RoleAlternationOff,
+			messages: toolSequence,
+			want:     toolSequence,
+		},
+		{
+			name:     "empty policy is off",
+			policy:   "",
+			messages: toolSequence,
+			want:     toolSequence,
+		},
+		{
+			name:     "merge already alternating",
+			policy:   RoleAlternationMerge,
+			messages: []llm.Message{system, question, answer},
+			want:     []llm.Message{system, question, answer},
+		},
+		{
+			name:     "merge tool note, tool result and user message",
+			policy:   RoleAlternationMerge,
+			messages: toolSequence,
+			want: []llm.Message{
+				system,
+				question,
+				{Role: RoleAssistant, Content: answer.Content + "\n\n" + toolNote.Content},
+				{Role: RoleUser, Content: toolResult.Content + "\n\n" + followUp.Content},
+			},
+		},
+		{
+			name:     "merge three in a row",
+			policy:   RoleAlternationMerge,
+			messages: []llm.Message{question, toolResult, followUp},
+			want: []llm.Message{
+				{Role: RoleUser, Content: question.Content + "\n\n" + toolResult.Content + "\n\n" + followUp.Content},
+			},
+		},
+		{
+			name:     "merge leaves system messages apart",
+			policy:   RoleAlternationMerge,
+			messages: []llm.Message{system, system, question},
+			want:     []llm.Message{system, system, question},
+		},
+		{
+			name:     "separator already alternating",
+			policy:   RoleAlternationSeparator,
+			messages: []llm.Message{system, question, answer},
+			want:     []llm.Message{system, question, answer},
+		},
+		{
+			name:     "separator tool note, tool result and user message",
+			policy:   RoleAlternationSeparator,
+			messages: toolSequence,
+			want: []llm.Message{
+				system,
+				question,
+				answer,
+				separatorMessages[RoleAssistant],
+				toolNote,
+				toolResult,
+				separatorMessages[RoleUser],
+				followUp,
+			},
+		},
+		{
+			name:     "separator leaves system messages apart",
+			policy:   RoleAlternationSeparator,
+			messages: []llm.Message{system, system, question},
+			want:     []llm.Message{system, system, question},
+		},
+		{
+			name:     "empty history",
+			policy:   RoleAlternationMerge,
+			messages: []llm.Message{},
+			want:     []llm.Message{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := normalizeRoles(test.messages, test.policy)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("normalizeRoles(%s) =\n%+v\nwant\n%+v", test.policy, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRolesKeepsInput(t *testing.T) {
+	messages := []llm.Message{
+		{Role: RoleUser, Content: "one"},
+		{Role: RoleUser, Content: "two"},
+	}
+	normalizeRoles(messages, RoleAlternationMerge)
+	if messages[0].Content != "one" || messages[1].Content != "two" {
+		t.Errorf("normalizeRoles changed its input: %+v", messages)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"time"
+)
+
+const defaultAutoContextTemplate = "Current date and time: {{datetime}} ({{timezone}}). Operating system: {{os}}. Working directory: {{cwd}}."
+
+// AutoContextConfig adds facts about the local environment to the system
+// prompt. The template is rendered again for every request, so the values
+// stay current without piling up in the conversation.
+type AutoContextConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Template string `yaml:"template"`
+}
+
+// autoContext renders the auto context template. Supported placeholders
+// are {{datetime}}, {{date}}, {{time}}, {{timezone}}, {{os}} and {{cwd}};
+// unknown ones are left as they are.
+func (app *App) autoContext() string {
+	if !app.config.AutoContext.Enabled {
+		return ""
+	}
+	template := app.config.AutoContext.Template
+	if template == "" {
+		template = defaultAutoContextTemplate
+	}
+
+	now := time.Now()
+	zone, _ := now.Zone()
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "unknown"
+	}
+	values := map[string]string{
+		"datetime": now.Format("2006-01-02 15:04:05"),
+		"date":     now.Format("2006-01-02"),
+		"time":     now.Format("15:04:05"),
+		"timezone": zone,
+		"os":       runtime.GOOS + "/" + runtime.GOARCH,
+		"cwd":      cwd,
+	}
+	return varPattern.ReplaceAllStringFunc(template, func(match string) string {
+		if value, ok := values[varPattern.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// withSession runs fn while holding sessionMu, so the auto-save never
+// writes a session in the middle of a command or turn.
+func (app *App) withSession(fn func()) {
+	app.sessionMu.Lock()
+	defer app.sessionMu.Unlock()
+	fn()
+}
+
+// startAutoSave saves the session every auto_save_interval, so a crash
+// loses at most that much of the conversation. Without it the session is
+// only saved on exit.
+func (app *App) startAutoSave() {
+	interval, _ := time.ParseDuration(app.config.AutoSaveInterval)
+	if !app.config.SaveSessions || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.ctx.Done():
+				return
+			case <-ticker.C:
+				var err error
+				app.withSession(func() { err = app.saveSession() })
+				if err != nil {
+					systemColor.Printf("Warning: Failed to auto-save session: %v\n", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// currentBranch names the live conversation in /diff.
+const currentBranch = "current"
+
+var (
+	diffAddedColor   = color.New(color.FgGreen)
+	diffRemovedColor = color.New(color.FgRed)
+)
+
+// branchCommand saves, restores and lists named snapshots of the
+// conversation, so alternate continuations can be tried from one point.
+func (app *App) branchCommand(args []string) {
+	if len(args) == 0 {
+		if len(app.branches) == 0 {
+			systemColor.Println("No branches. Use /branch save <name> to create one.")
+			return
+		}
+		names := make([]string, 0, len(app.branches))
+		for name := range app.branches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			systemColor.Printf("  %s (%d messages)\n", name, len(app.branches[name]))
+		}
+		return
+	}
+	if len(args) != 2 || (args[0] != "save" && args[0] != "load") {
+		systemColor.Println("Usage: /branch [save|load <name>]")
+		return
+	}
+
+	name := args[1]
+	switch args[0] {
+	case "save":
+		if name == currentBranch {
+			systemColor.Printf("%q is reserved for the live conversation.\n", currentBranch)
+			return
+		}
+		records, err := app.conversation.GetAll()
+		if err != nil {
+			systemColor.Printf("Failed to read conversation: %v\n", err)
+			return
+		}
+		if app.branches == nil {
+			app.branches = map[string][]llm.Message{}
+		}
+		app.branches[name] = messagesOf(records)
+		systemColor.Printf("Saved branch %s (%d messages).\n", name, len(records))
+	case "load":
+		messages, ok := app.branches[name]
+		if !ok {
+			systemColor.Printf("Unknown branch: %s\n", name)
+			return
+		}
+		if err := app.conversation.Clear(); err != nil {
+			systemColor.Printf("Failed to load branch: %v\n", err)
+			return
+		}
+		for _, message := range messages {
+			if err := app.conversation.Save(generateMsgID(), message); err != nil {
+				systemColor.Printf("Failed to load branch: %v\n", err)
+				return
+			}
+		}
+		// The messages have new IDs, so nothing that refers to the old
+		// ones applies any more.
+		if len(app.pins) > 0 || len(app.tags) > 0 {
+			systemColor.Println("Pins and tags were cleared.")
+		}
+		app.lastUserMsgID = ""
+		app.lastUserInput = ""
+		app.canContinue = false
+		app.pins = nil
+		app.tags = nil
+		app.variants = nil
+		systemColor.Printf("Switched to branch %s.\n", name)
+	}
+}
+
+// branchMessages returns the messages of a saved branch or, for
+// currentBranch, of the live conversation.
+func (app *App) branchMessages(name string) ([]llm.Message, bool) {
+	if name == currentBranch {
+		records, err := app.conversation.GetAll()
+		if err != nil {
+			systemColor.Printf("Failed to read conversation: %v\n", err)
+			return nil, false
+		}
+		return messagesOf(records), true
+	}
+	messages, ok := app.branches[name]
+	return messages, ok
+}
+
+func lastAssistantMessage(messages []llm.Message) (string, bool) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleAssistant {
+			return messages[i].Content, true
+		}
+	}
+	return "", false
+}
+
+// diffCommand shows a line diff of the last assistant message of two
+// branches.
+func (app *App) diffCommand(args []string) {
+	if len(args) != 2 {
+		systemColor.Printf("Usage: /diff <branchA> <branchB> (use %q for the live conversation)\n", currentBranch)
+		return
+	}
+
+	var answers [2]string
+	for i, name := range args {
+		messages, ok := app.branchMessages(name)
+		if !ok {
+			systemColor.Printf("Unknown branch: %s\n", name)
+			return
+		}
+		answer, ok := lastAssistantMessage(messages)
+		if !ok {
+			systemColor.Printf("Branch %s has no assistant message yet.\n", name)
+			return
+		}
+		answers[i] = answer
+	}
+
+	diffRemovedColor.Printf("--- %s\n", args[0])
+	diffAddedColor.Printf("+++ %s\n", args[1])
+	for _, line := range diffLines(strings.Split(answers[0], "\n"), strings.Split(answers[1], "\n")) {
+		switch line[0] {
+		case '-':
+			diffRemovedColor.Println(line)
+		case '+':
+			diffAddedColor.Println(line)
+		default:
+			color.New().Println(line)
+		}
+	}
+}
+
+// diffLines returns a line diff of a and b based on their longest common
+// subsequence. Each line is prefixed with "- ", "+ " or "  ".
+func diffLines(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return diff
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+var defaultCitationFields = []string{"url", "source", "link", "href"}
+
+// CitationsConfig lists the tools whose results carry sources. Sources are
+// read from the Fields of JSON results and listed under the answer.
+type CitationsConfig struct {
+	Tools  []string `yaml:"tools"`
+	Fields []string `yaml:"fields"`
+}
+
+// collectSources remembers the sources found in a tool result for the
+// footer of this turn's answer.
+func (app *App) collectSources(toolName, result string) {
+	citations := app.config.Citations
+	if !matchesToolPattern(toolName, citations.Tools) {
+		return
+	}
+	fields := citations.Fields
+	if len(fields) == 0 {
+		fields = defaultCitationFields
+	}
+	var data any
+	if err := json.Unmarshal([]byte(result), &data); err != nil {
+		return
+	}
+	sources := findSources(data, fields)
+	slices.Sort(sources)
+	for _, source := range sources {
+		if !slices.Contains(app.turnSources, source) {
+			app.turnSources = append(app.turnSources, source)
+		}
+	}
+}
+
+// findSources walks a decoded JSON value and returns the non-empty string
+// values of the given keys, matched without regard to case.
+func findSources(data any, fields []string) []string {
+	var sources []string
+	switch value := data.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if text, ok := field.(string); ok && strings.TrimSpace(text) != "" &&
+				slices.ContainsFunc(fields, func(f string) bool { return strings.EqualFold(f, key) }) {
+				sources = append(sources, strings.TrimSpace(text))
+				continue
+			}
+			sources = append(sources, findSources(field, fields)...)
+		}
+	case []any:
+		for _, item := range value {
+			sources = append(sources, findSources(item, fields)...)
+		}
+	}
+	return sources
+}
+
+// sourcesFooter formats the sources collected during the turn, or returns
+// "" when there are none.
+func (app *App) sourcesFooter() string {
+	if len(app.turnSources) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nSources:")
+	for _, source := range app.turnSources {
+		b.WriteString("\n- " + source)
+	}
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are tried in order until one is installed.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}},
+	"linux":   {{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+}
+
+func readClipboard() (string, error) {
+	for _, args := range clipboardCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		output, err := exec.Command(args[0], args[1:]...).Output()
+		if err != nil {
+			return "", err
+		}
+		return string(output), nil
+	}
+	return "", errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+}
+
+// pasteCommand sends the clipboard contents as the next message, after the
+// optional text typed with the command.
+func (app *App) pasteCommand(text string) {
+	clipboard, err := readClipboard()
+	if err != nil {
+		systemColor.Printf("Clipboard unavailable: %v\n", err)
+		return
+	}
+	if strings.TrimSpace(clipboard) == "" {
+		systemColor.Println("The clipboard is empty.")
+		return
+	}
+
+	message := clipboard
+	if text != "" {
+		message = text + "\n\n" + clipboard
+	}
+	systemColor.Printf("Pasted %d lines from the clipboard.\n", strings.Count(strings.TrimRight(clipboard, "\n"), "\n")+1)
+	if err := app.processTurn(message); err != nil {
+		systemColor.Printf("Error: %v\n", err)
+	}
+}
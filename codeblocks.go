@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeBlock is a fenced code block of an answer. filename is "" when the
+// answer does not suggest one.
+type codeBlock struct {
+	language string
+	filename string
+	content  string
+}
+
+var (
+	// infoFilenamePattern finds a filename in a fence info string, such as
+	// "go // main.go", "go main.go" or `python title="app.py"`.
+	infoFilenamePattern = regexp.MustCompile(`(?:title|file|filename)=["']?([^"'\s]+)|(?:^|\s)(?://|#)?\s*([\w./-]+\.\w+)\s*$`)
+	// commentFilenamePattern finds a filename in a leading comment such as
+	// "// main.go" or "# file: app.py".
+	commentFilenamePattern = regexp.MustCompile(`^\s*(?://|#|--|/\*|<!--)\s*(?:file(?:name)?:\s*)?([\w./-]+\.\w+)\s*(?:\*/|-->)?\s*$`)
+)
+
+// parseCodeBlocks returns the fenced code blocks of text, in order.
+func parseCodeBlocks(text string) []codeBlock {
+	var blocks []codeBlock
+	var current *codeBlock
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current == nil {
+			if info, ok := strings.CutPrefix(trimmed, "```"); ok {
+				language, _, _ := strings.Cut(strings.TrimSpace(info), " ")
+				current = &codeBlock{language: language, filename: infoFilename(info)}
+				lines = nil
+			}
+			continue
+		}
+		if trimmed == "```" {
+			if current.filename == "" && len(lines) > 0 {
+				if match := commentFilenamePattern.FindStringSubmatch(lines[0]); match != nil {
+					current.filename = match[1]
+				}
+			}
+			current.content = strings.Join(lines, "\n") + "\n"
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return blocks
+}
+
+func infoFilename(info string) string {
+	match := infoFilenamePattern.FindStringSubmatch(strings.TrimSpace(info))
+	if match == nil {
+		return ""
+	}
+	if match[1] != "" {
+		return match[1]
+	}
+	return match[2]
+}
+
+// codeBlockPath resolves filename inside dir, refusing paths that would
+// end up outside of it.
+func codeBlockPath(dir, filename string) (string, error) {
+	if filepath.IsAbs(filename) {
+		return "", fmt.Errorf("refusing absolute path %s", filename)
+	}
+	path := filepath.Join(dir, filename)
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing path %s outside of %s", filename, dir)
+	}
+	return path, nil
+}
+
+// writeCodeBlock writes content to path, asking before overwriting an
+// existing file. It reports whether the file was written.
+func writeCodeBlock(path, content string) (bool, error) {
+	if _, err := os.Stat(path); err == nil {
+		if !confirm(fmt.Sprintf("%s exists. Overwrite it?", path)) {
+			return false, nil
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// extractCode saves the code blocks of an answer that suggest a filename
+// under app.extractDir.
+func (app *App) extractCode(response string) {
+	for _, block := range parseCodeBlocks(response) {
+		if block.filename == "" {
+			continue
+		}
+		path, err := codeBlockPath(app.extractDir, block.filename)
+		if err != nil {
+			systemColor.Printf("Skipping code block: %v\n", err)
+			continue
+		}
+		written, err := writeCodeBlock(path, block.content)
+		switch {
+		case err != nil:
+			systemColor.Printf("Failed to save %s: %v\n", path, err)
+		case written:
+			systemColor.Printf("Saved code block to %s\n", path)
+		}
+	}
+}
+
+// codeExtensions maps common fence languages to a file extension for
+// blocks without a suggested filename.
+var codeExtensions = map[string]string{
+	"bash": "sh", "c": "c", "cpp": "cpp", "css": "css", "go": "go", "html": "html",
+	"java": "java", "javascript": "js", "js": "js", "json": "json", "markdown": "md",
+	"md": "md", "python": "py", "py": "py", "ruby": "rb", "rust": "rs", "sh": "sh",
+	"shell": "sh", "sql": "sql", "ts": "ts", "typescript": "ts", "yaml": "yaml", "yml": "yaml",
+}
+
+// exportCode writes every code block of the assistant messages to dir,
+// under its suggested filename or as block-<n>.<ext>, where n is the
+// position of the block in the conversation.
+func (app *App) exportCode(dir string) {
+	if dir == "" {
+		systemColor.Println("Usage: /export-code <dir>")
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+
+	position, blocks, messages := 0, 0, 0
+	for _, message := range messagesOf(records) {
+		if message.Role != RoleAssistant {
+			continue
+		}
+		exported := 0
+		for _, block := range parseCodeBlocks(message.Content) {
+			position++
+			filename := block.filename
+			if filename == "" {
+				extension, ok := codeExtensions[strings.ToLower(block.language)]
+				if !ok {
+					extension = "txt"
+				}
+				filename = fmt.Sprintf("block-%03d.%s", position, extension)
+			}
+			path, err := codeBlockPath(dir, filename)
+			if err != nil {
+				systemColor.Printf("Skipping code block: %v\n", err)
+				continue
+			}
+			written, err := writeCodeBlock(path, block.content)
+			if err != nil {
+				systemColor.Printf("Failed to save %s: %v\n", path, err)
+				continue
+			}
+			if written {
+				blocks++
+				exported++
+			}
+		}
+		if exported > 0 {
+			messages++
+		}
+	}
+	systemColor.Printf("Exported %d code blocks from %d messages to %s.\n", blocks, messages, dir)
+}
+
+// optionalDirFlag is a flag that may be given alone, meaning the current
+// directory, or with a directory as --flag=<dir>. Like a boolean flag, it
+// does not take the next argument as its value; bare records that it was
+// given alone, so a directory left after it can be reported.
+type optionalDirFlag struct {
+	dir  string
+	bare bool
+}
+
+func (f *optionalDirFlag) String() string { return f.dir }
+
+func (f *optionalDirFlag) Set(value string) error {
+	f.bare = value == "true"
+	if f.bare {
+		value = "."
+	}
+	f.dir = value
+	return nil
+}
+
+func (f *optionalDirFlag) IsBoolFlag() bool { return true }
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(app *App, args string)
+}
+
+// commands is filled in init because /help refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"/help", "/help", "List available commands", func(app *App, args string) { showHelp() }},
+		{"/mcp", "/mcp status|restart <name>|kill <name>", "Show the MCP server processes, or restart or kill one", func(app *App, args string) { app.mcpCommand(strings.Fields(args)) }},
+		{"/reload", "/reload", "Reload config.yml", func(app *App, args string) { app.reload() }},
+		{"/config", "/config [diff|save]", "Show the effective config, how it differs from config.yml, or save it there", func(app *App, args string) { app.configCommand(strings.Fields(args)) }},
+		{"/clear", "/clear", "Clear the conversation history", func(app *App, args string) { app.clear() }},
+		{"/reset", "/reset [--mcp]", "Start a fresh session with the same config, optionally reconnecting MCP servers", func(app *App, args string) { app.resetSession(strings.TrimSpace(args)) }},
+		{"/temp", "/temp [ramp <spec>]", "Show the temperature or set a ramp", func(app *App, args string) { app.temperatureCommand(strings.Fields(args)) }},
+		{"/set", "/set [<key> <value> [--save]]", "Show or change model options", func(app *App, args string) { app.setCommand(strings.Fields(args)) }},
+		{"/format", "/format [json [schemafile]|yaml|text]", "Show or change the format of the next answers", func(app *App, args string) { app.formatCommand(strings.Fields(args)) }},
+		{"/opts", "/opts [set [tools.]<option> <value>|reset]", "Show or override the sampling options sent to the models", func(app *App, args string) { app.optsCommand(strings.Fields(args)) }},
+		{"/window", "/window [<n>|all|tokens <n>]", "Show or change how much history is sent", func(app *App, args string) { app.windowCommand(strings.Fields(args)) }},
+		{"/overlay", "/overlay [text|clear]", "Manage system prompt overlays", func(app *App, args string) { app.overlayCommand(args) }},
+		{"/paste", "/paste [text]", "Send the clipboard contents, after the optional text", func(app *App, args string) { app.pasteCommand(args) }},
+		{"/continue", "/continue", "Resume an interrupted answer", func(app *App, args string) { app.continueResponse() }},
+		{"/model", "/model [name]", "Show or switch the chat model (aliases allowed)", func(app *App, args string) { app.modelCommand(args) }},
+		{"/regen", "/regen <model>", "Answer the last message again with another model", func(app *App, args string) { app.regenerate(args) }},
+		{"/vary", "/vary <temperature> [count] | /vary pick <n>", "Sample several answers to the last message and keep one", func(app *App, args string) { app.varyCommand(strings.Fields(args)) }},
+		{"/compare", "/compare <modelA> <modelB>", "Answer the last message with two models without changing the conversation", func(app *App, args string) { app.compareModels(strings.Fields(args)) }},
+		{"/branch", "/branch [save|load <name>]", "List, save or switch conversation branches", func(app *App, args string) { app.branchCommand(strings.Fields(args)) }},
+		{"/diff", "/diff <branchA> <branchB>", "Diff the last answers of two branches", func(app *App, args string) { app.diffCommand(strings.Fields(args)) }},
+		{"/var", "/var [list|set <name> <value>|unset <name>]", "Manage variables used as {{name}} in messages", func(app *App, args string) { app.varCommand(args) }},
+		{"/tag", "/tag <text>", "Tag the last message", func(app *App, args string) { app.tagCommand(args) }},
+		{"/find", "/find <tag>", "List the messages with a tag", func(app *App, args string) { app.findTagged(args) }},
+		{"/export", "/export html <file> [--theme light|dark]", "Save the conversation as a self-contained HTML page", func(app *App, args string) { app.exportCommand(strings.Fields(args)) }},
+		{"/export-code", "/export-code <dir>", "Save every code block of the answers to a directory", func(app *App, args string) { app.exportCode(args) }},
+		{"/detach", "/detach [prompt]", "Continue the agent in the background and start a new session", func(app *App, args string) { app.detach(args) }},
+		{"/tools", "/tools [on|off]", "Show, resume or pause the tools query", func(app *App, args string) { app.toolsCommand(args) }},
+		{"/notools", "/notools", "Pause the tools query for this session", func(app *App, args string) { app.toolsCommand("off") }},
+		{"/pin", "/pin <index>", "Always send a message, whatever the history window", func(app *App, args string) { app.pinCommand(args, true) }},
+		{"/unpin", "/unpin <index>", "Unpin a message", func(app *App, args string) { app.pinCommand(args, false) }},
+		{"/pins", "/pins", "List the pinned messages", func(app *App, args string) { app.listPins() }},
+		{"/search", "/search [--all] <text>", "Search the conversation, or every stored session", func(app *App, args string) { app.searchCommand(args) }},
+		{"/inject", "/inject tool <name> <result>", "Add a fake tool result and let the model answer it", func(app *App, args string) { app.injectCommand(args) }},
+		{"/summarize", "/summarize [file]", "Summarize the whole conversation, optionally saving the summary to a file", func(app *App, args string) { app.summarizeConversation(strings.TrimSpace(args)) }},
+		{"/why", "/why", "Ask the tools model to explain its last tool decision", func(app *App, args string) { app.explainToolDecision() }},
+		{"/last", "/last", "Show the last raw request and response", func(app *App, args string) { app.showLastExchange() }},
+		{"/lasttool", "/lasttool", "Show the full result of the last tool call", func(app *App, args string) { app.showLastTool() }},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// commandCandidates returns the commands that start with prefix.
+func commandCandidates(prefix string) []string {
+	var candidates []string
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd.name, prefix) {
+			candidates = append(candidates, cmd.name)
+		}
+	}
+	return candidates
+}
+
+// suggestCommands returns the commands name could have been meant as:
+// the ones it is a prefix of or, failing that, the ones within a small
+// edit distance.
+func suggestCommands(name string) []string {
+	if candidates := commandCandidates(name); len(candidates) > 0 {
+		return candidates
+	}
+	var suggestions []string
+	for _, cmd := range commands {
+		if levenshtein(name, cmd.name) <= 2 {
+			suggestions = append(suggestions, cmd.name)
+		}
+	}
+	return suggestions
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func (app *App) handleCommand(input string) {
+	// A trailing tab (typed before Enter) asks for completions.
+	if prefix, ok := strings.CutSuffix(input, "\t"); ok && !strings.Contains(prefix, " ") {
+		systemColor.Printf("Commands: %s\n", strings.Join(commandCandidates(prefix), " "))
+		return
+	}
+
+	name, args, _ := strings.Cut(strings.TrimSpace(input), " ")
+
+	if cmd, ok := findCommand(name); ok {
+		cmd.run(app, strings.TrimSpace(args))
+		return
+	}
+
+	suggestions := suggestCommands(name)
+	switch len(suggestions) {
+	case 0:
+		systemColor.Printf("Unknown command: %s. Type /help for a list of commands.\n", name)
+	case 1:
+		systemColor.Printf("Unknown command: %s. Did you mean %s?\n", name, suggestions[0])
+	default:
+		systemColor.Printf("Unknown command: %s. Did you mean one of: %s?\n", name, strings.Join(suggestions, ", "))
+	}
+}
+
+func showHelp() {
+	for _, cmd := range commands {
+		systemColor.Printf("  %-28s %s\n", cmd.usage, cmd.help)
+	}
+}
+
+func (app *App) reload() {
+	newConfig, err := readConfig()
+	if err != nil {
+		systemColor.Printf("Reload failed, keeping previous config: %v\n", err)
+		return
+	}
+	if kept := keepSessionSettings(&newConfig, app.config); len(kept) > 0 {
+		systemColor.Printf("Keeping the values set during this session: %s\n", strings.Join(kept, ", "))
+	}
+
+	changed := changedConfigFields(app.config, newConfig)
+	if len(changed) == 0 {
+		systemColor.Println("Config reloaded: no changes.")
+		return
+	}
+
+	if err := configureHTTPTransport(newConfig); err != nil {
+		systemColor.Printf("Reload failed, keeping previous config: %v\n", err)
+		return
+	}
+
+	oldConfig := app.config
+	app.config = newConfig
+	setASCIIIcons(newConfig.ASCIIIcons)
+	systemColor.Printf("Config reloaded, changed: %s\n", strings.Join(changed, ", "))
+
+	if oldConfig.EnableMCP != newConfig.EnableMCP || !reflect.DeepEqual(oldConfig.MCP, newConfig.MCP) || oldConfig.ToolsFile != newConfig.ToolsFile {
+		systemColor.Println("Tools changed, re-initializing...")
+		app.closeMCP()
+		if err := app.initMCP(); err != nil {
+			systemColor.Printf("Warning: Failed to initialize MCP client: %v\n", err)
+			app.closeMCP()
+		}
+	}
+}
+
+func (app *App) clear() {
+	if err := app.resetConversation(); err != nil {
+		systemColor.Printf("Failed to clear conversation: %v\n", err)
+		return
+	}
+	systemColor.Println("Conversation cleared.")
+}
+
+// regenerate drops everything after the last user message and answers it
+// again with model, without switching the configured chat model.
+func (app *App) regenerate(model string) {
+	if model == "" {
+		systemColor.Println("Usage: /regen <model>")
+		return
+	}
+	model = app.config.resolveModel(model)
+	found, err := app.dropLastAnswer()
+	if err != nil {
+		systemColor.Printf("Regenerate failed: %v\n", err)
+		return
+	}
+	if !found {
+		systemColor.Println("Nothing to regenerate.")
+		return
+	}
+
+	if app.turn > 0 {
+		app.turn--
+	}
+	if _, err := app.runTurn(app.ctx, app.lastUserInput, model, turnRegenerate); err != nil {
+		systemColor.Printf("Regenerate failed: %v\n", err)
+	}
+}
+
+// dropLastAnswer removes everything after the last user message. It
+// reports false when there is no user message.
+func (app *App) dropLastAnswer() (bool, error) {
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		return false, fmt.Errorf("failed to read conversation: %w", err)
+	}
+	found := false
+	for _, record := range records {
+		if found {
+			if err := app.conversation.Delete(record.Id); err != nil {
+				return true, fmt.Errorf("failed to remove previous answer: %w", err)
+			}
+		}
+		if record.Id == app.lastUserMsgID {
+			found = true
+		}
+	}
+	return found, nil
+}
+
+func (app *App) temperatureCommand(args []string) {
+	schedule := app.config.TemperatureSchedule
+	if len(args) == 0 {
+		systemColor.Printf("Temperature: %.2f (base %.2f, ramp %s, turn %d)\n",
+			schedule.temperatureFor(app.config.Temperature, app.turn), app.config.Temperature, schedule, app.turn)
+		return
+	}
+	if args[0] != "ramp" || len(args) != 2 {
+		systemColor.Println("Usage: /temp [ramp <v1,v2,...|decay:<factor>|off>]")
+		return
+	}
+
+	schedule, err := parseTemperatureSchedule(args[1])
+	if err != nil {
+		systemColor.Printf("Invalid ramp: %v\n", err)
+		return
+	}
+	app.config.TemperatureSchedule = schedule
+	app.config.sources["temperature_schedule"] = sourceSet
+	systemColor.Printf("Temperature ramp set to %s.\n", schedule)
+}
+
+func (app *App) overlayCommand(text string) {
+	switch text {
+	case "":
+		if len(app.overlays) == 0 {
+			systemColor.Println("No overlays. Use /overlay <text> to add one.")
+			return
+		}
+		for i, overlay := range app.overlays {
+			systemColor.Printf("  %d. %s\n", i+1, overlay)
+		}
+	case "clear":
+		app.overlays = nil
+		systemColor.Println("Overlays cleared.")
+	default:
+		app.overlays = append(app.overlays, text)
+		systemColor.Printf("Overlay added (%d active).\n", len(app.overlays))
+	}
+}
+
+// windowCommand shows or changes how much history is sent with each
+// request, for this session.
+func (app *App) windowCommand(args []string) {
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "all":
+		app.config.HistoryWindow = -1
+		app.config.HistoryTokens = 0
+	case len(args) == 2 && args[0] == "tokens":
+		tokens, err := strconv.Atoi(args[1])
+		if err != nil || tokens <= 0 {
+			systemColor.Println("Usage: /window tokens <n> (n > 0)")
+			return
+		}
+		app.config.HistoryTokens = tokens
+	case len(args) == 1:
+		window, err := strconv.Atoi(args[0])
+		if err != nil || window <= 0 {
+			systemColor.Println("Usage: /window [<n>|all|tokens <n>] (n > 0)")
+			return
+		}
+		app.config.HistoryWindow = window
+		app.config.HistoryTokens = 0
+	default:
+		systemColor.Println("Usage: /window [<n>|all|tokens <n>]")
+		return
+	}
+	if len(args) > 0 {
+		app.config.sources["history_window"] = sourceSet
+		app.config.sources["history_tokens"] = sourceSet
+	}
+	systemColor.Printf("History window: %s.\n", app.config.historyWindow())
+}
+
+// toolsCommand pauses or resumes the tools query for this session.
+func (app *App) toolsCommand(arg string) {
+	switch arg {
+	case "on":
+		app.toolsPaused = false
+	case "off":
+		app.toolsPaused = true
+	case "":
+	default:
+		systemColor.Println("Usage: /tools [on|off]")
+		return
+	}
+	systemColor.Printf("Tools query: %s (%d tools loaded).\n", toolsState(app), len(app.ollamaTools))
+}
+
+func (app *App) showConfig() {
+	formatted, err := formatConfig(app.config)
+	if err != nil {
+		systemColor.Printf("Failed to format config: %v\n", err)
+		return
+	}
+	fmt.Fprint(app.out, formatted)
+}
+
+func (app *App) showLastTool() {
+	if app.lastToolName == "" {
+		systemColor.Println("No tool has been called yet.")
+		return
+	}
+	toolColor.Printf("%s Last tool: %s (%d bytes)\n", iconTool, app.lastToolName, len(app.lastToolResult))
+	fmt.Fprintln(app.out, app.lastToolResult)
+}
+
+// changedConfigFields returns the yaml names of the fields that differ
+// between two configs.
+// keepSessionSettings copies the values changed with /set, /model, /window
+// or /temp from oldConfig into newConfig and returns their keys.
+func keepSessionSettings(newConfig *Config, oldConfig Config) []string {
+	oldValue := reflect.ValueOf(oldConfig)
+	newValue := reflect.ValueOf(newConfig).Elem()
+	var kept []string
+	for key, source := range oldConfig.sources {
+		if source != sourceSet {
+			continue
+		}
+		oldField, ok := configField(oldValue, key)
+		if !ok {
+			continue
+		}
+		field, _ := configField(newValue, key)
+		field.Set(oldField)
+		newConfig.sources[key] = sourceSet
+		kept = append(kept, key)
+	}
+	sort.Strings(kept)
+	return kept
+}
+
+func changedConfigFields(oldConfig, newConfig Config) []string {
+	var changed []string
+	oldValue := reflect.ValueOf(oldConfig)
+	newValue := reflect.ValueOf(newConfig)
+	configType := oldValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		if !configType.Field(i).IsExported() {
+			continue
+		}
+		if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		name := configType.Field(i).Tag.Get("yaml")
+		if name == "" {
+			name = configType.Field(i).Name
+		}
+		changed = append(changed, name)
+	}
+	return changed
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// compareModels answers the last user message with two models, one after
+// the other, and prints both answers with their stats. Nothing is added to
+// the conversation and tools are not offered.
+func (app *App) compareModels(args []string) {
+	if len(args) != 2 {
+		systemColor.Println("Usage: /compare <modelA> <modelB>")
+		return
+	}
+	messages, err := app.lastTurnMessages()
+	if err != nil {
+		systemColor.Printf("Compare failed: %v\n", err)
+		return
+	}
+	if messages == nil {
+		systemColor.Println("There is no message to compare answers for yet.")
+		return
+	}
+	for _, model := range args {
+		model = app.config.resolveModel(model)
+		systemColor.Printf("Asking %s...\n", model)
+		start := time.Now()
+		answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+			Model:    model,
+			Messages: messages,
+			Options:  app.chatOptions(),
+		})
+		if err != nil {
+			systemColor.Printf("Failed to query %s: %v\n", model, err)
+			continue
+		}
+		assistantColor.Printf("--- %s ---\n", model)
+		fmt.Fprintln(app.out, newRedactor(app.config.Redact).redact(strings.TrimSpace(answer.Message.Content)))
+		systemColor.Println(compareStats(app.config, model, answer, time.Since(start)))
+	}
+}
+
+func compareStats(config Config, model string, answer llm.Answer, elapsed time.Duration) string {
+	stats := fmt.Sprintf("%s: %s, %d tokens in, %d out", model, elapsed.Round(time.Millisecond), answer.PromptEvalCount, answer.EvalCount)
+	if answer.EvalDuration > 0 {
+		stats += fmt.Sprintf(", %.1f tokens/s", float64(answer.EvalCount)/time.Duration(answer.EvalDuration).Seconds())
+	}
+	if price, ok := config.price(model); ok {
+		stats += fmt.Sprintf(", $%.4f", price.cost(answer))
+	}
+	return stats
+}
+
+// lastTurnMessages builds the request that answered the last user message,
+// without the answer or anything after it. It returns nil when there is no
+// user message yet.
+func (app *App) lastTurnMessages() ([]llm.Message, error) {
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversation: %w", err)
+	}
+	end := -1
+	for i, record := range records {
+		if record.Id == app.lastUserMsgID {
+			end = i
+		}
+	}
+	if end < 0 {
+		return nil, nil
+	}
+
+	history := getLastMessages(messagesOf(records[:end+1]), app.config, app.pinnedIndexes(records[:end+1]))
+	history[len(history)-1].Content = app.expandVars(app.config.augmentUserMessage(app.lastUserInput))
+	return app.buildMessages(history), nil
+}
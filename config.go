@@ -0,0 +1,586 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+type MCPServer struct {
+	Name    string   `yaml:"name"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+type MCPConfig struct {
+	Servers          []MCPServer `yaml:"servers"`
+	Lazy             bool        `yaml:"lazy"`
+	MaxActiveServers int         `yaml:"max_active_servers"`
+	InitTimeout      string      `yaml:"init_timeout"`
+}
+
+type FewShotMessage struct {
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+}
+
+type Config struct {
+	OllamaURL                string                `yaml:"ollama_url"`
+	OllamaURLs               []string              `yaml:"ollama_urls"`
+	APIBasePath              string                `yaml:"api_base_path"`
+	HTTPProxy                string                `yaml:"http_proxy"`
+	CACertFile               string                `yaml:"ca_cert_file"`
+	Headers                  map[string]string     `yaml:"headers"`
+	UserAgent                string                `yaml:"user_agent"`
+	ChatModel                string                `yaml:"chat_model"`
+	ToolsModel               string                `yaml:"tools_model"`
+	Aliases                  map[string]string     `yaml:"aliases"`
+	ModelTiers               []ModelTier           `yaml:"model_tiers"`
+	Pricing                  map[string]ModelPrice `yaml:"pricing"`
+	SessionBudget            float64               `yaml:"session_budget"`
+	SystemPrompt             string                `yaml:"system_prompt"`
+	SystemPromptLayers       []string              `yaml:"system_prompt_layers"`
+	SystemPromptPosition     string                `yaml:"system_prompt_position"`
+	AutoContext              AutoContextConfig     `yaml:"auto_context"`
+	Language                 string                `yaml:"language"`
+	FewShot                  []FewShotMessage      `yaml:"few_shot"`
+	RoleAlternation          string                `yaml:"role_alternation"`
+	RoleMap                  RoleMap               `yaml:"role_map"`
+	UserMessagePrefix        string                `yaml:"user_message_prefix"`
+	UserMessageSuffix        string                `yaml:"user_message_suffix"`
+	StoreAugmentedMessage    bool                  `yaml:"store_augmented_message"`
+	ContinuePrompt           string                `yaml:"continue_prompt"`
+	EnableMCP                bool                  `yaml:"enable_mcp"`
+	ToolsFile                string                `yaml:"tools_file"`
+	AgentMode                bool                  `yaml:"agent_mode"`
+	AgentMaxSteps            int                   `yaml:"agent_max_steps"`
+	SingleModelTools         bool                  `yaml:"single_model_tools"`
+	ToolsEarlyAbort          bool                  `yaml:"tools_early_abort"`
+	Temperature              float64               `yaml:"temperature"`
+	RepeatLastN              int                   `yaml:"repeat_last_n"`
+	RepeatPenalty            float64               `yaml:"repeat_penalty"`
+	NumCtx                   int                   `yaml:"num_ctx"`
+	TopK                     int                   `yaml:"top_k"`
+	TopP                     float64               `yaml:"top_p"`
+	KeepAlive                string                `yaml:"keep_alive"`
+	WarmUp                   bool                  `yaml:"warm_up"`
+	WarmUpInterval           string                `yaml:"warm_up_interval"`
+	ToolsTemperature         float64               `yaml:"tools_temperature"`
+	ToolsRepeatLastN         int                   `yaml:"tools_repeat_last_n"`
+	ToolsRepeatPenalty       float64               `yaml:"tools_repeat_penalty"`
+	ASCIIIcons               bool                  `yaml:"ascii_icons"`
+	Redact                   []string              `yaml:"redact"`
+	RedactHistory            bool                  `yaml:"redact_history"`
+	MaxToolResultBytes       int                   `yaml:"max_tool_result_bytes"`
+	ToolResultChunkBytes     int                   `yaml:"tool_result_chunk_bytes"`
+	ToolPostProcessors       []ToolPostProcessor   `yaml:"tool_post_processors"`
+	ToolSummary              ToolSummaryConfig     `yaml:"tool_summary"`
+	ConversationSummary      ConversationSummary   `yaml:"conversation_summary"`
+	Citations                CitationsConfig       `yaml:"citations"`
+	ToolOutputGuard          ToolGuardConfig       `yaml:"tool_output_guard"`
+	ToolErrorPolicy          string                `yaml:"tool_error_policy"`
+	ToolCallTimeout          string                `yaml:"tool_call_timeout"`
+	ToolCallRetries          int                   `yaml:"tool_call_retries"`
+	ToolLimits               map[string]ToolLimit  `yaml:"tool_limits"`
+	SafeModeToolPatterns     []string              `yaml:"safe_mode_tool_patterns"`
+	ConfirmTools             []string              `yaml:"confirm_tools"`
+	ToolPreviews             []ToolPreview         `yaml:"tool_previews"`
+	InlineToolCalls          bool                  `yaml:"inline_tool_calls"`
+	InlineToolCallPattern    string                `yaml:"inline_tool_call_pattern"`
+	InlineMaxCalls           int                   `yaml:"inline_max_calls"`
+	ToolCallNotes            bool                  `yaml:"tool_call_notes"`
+	ToolCallNotePattern      string                `yaml:"tool_call_note_pattern"`
+	ToolTrigger              ToolTriggerConfig     `yaml:"tool_trigger"`
+	RefusalRetry             RefusalRetryConfig    `yaml:"refusal_retry"`
+	TemperatureSchedule      TemperatureSchedule   `yaml:"temperature_schedule"`
+	HistoryWindow            int                   `yaml:"history_window"`
+	HistoryTokens            int                   `yaml:"history_tokens"`
+	Retention                RetentionConfig       `yaml:"retention"`
+	AlwaysKeepLastToolResult bool                  `yaml:"always_keep_last_tool_result"`
+	OnStart                  OnStartConfig         `yaml:"on_start"`
+	InputFilter              string                `yaml:"input_filter"`
+	StorageBackend           string                `yaml:"storage_backend"`
+	StoragePath              string                `yaml:"storage_path"`
+	SaveSessions             bool                  `yaml:"save_sessions"`
+	AutoSaveInterval         string                `yaml:"auto_save_interval"`
+	MessageTTL               string                `yaml:"message_ttl"`
+	SessionsDir              string                `yaml:"sessions_dir"`
+	SessionTitle             string                `yaml:"session_title"`
+	MCP                      MCPConfig             `yaml:"mcp"`
+
+	// sources records where each value came from, keyed by yaml name.
+	sources map[string]string
+}
+
+const (
+	defaultAgentMaxSteps  = 5
+	defaultInlineMaxCalls = 3
+	defaultNumCtx         = 25920
+	defaultMCPInitTimeout = "30s"
+)
+
+const (
+	sourceDefault = "default"
+	sourceYAML    = "yaml"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+	sourceSet     = "/set"
+)
+
+// envOverrides lists the environment variables that override config
+// values, keyed by the yaml name of the field.
+var envOverrides = []struct {
+	key string
+	env string
+}{
+	{"ollama_url", "OLLAMA_HOST"},
+	{"api_base_path", "API_BASE_PATH"},
+	{"user_agent", "USER_AGENT"},
+	{"chat_model", "LLM_CHAT"},
+	{"tools_model", "LLM_WITH_TOOLS_SUPPORT"},
+	{"system_prompt", "SYSTEM_PROMPT"},
+	{"system_prompt_position", "SYSTEM_PROMPT_POSITION"},
+	{"role_alternation", "ROLE_ALTERNATION"},
+	{"enable_mcp", "ENABLE_MCP"},
+	{"agent_mode", "AGENT_MODE"},
+	{"agent_max_steps", "AGENT_MAX_STEPS"},
+	{"single_model_tools", "SINGLE_MODEL_TOOLS"},
+	{"tools_early_abort", "TOOLS_EARLY_ABORT"},
+	{"temperature", "TEMPERATURE"},
+	{"repeat_last_n", "REPEAT_LAST_N"},
+	{"repeat_penalty", "REPEAT_PENALTY"},
+	{"num_ctx", "NUM_CTX"},
+	{"history_window", "HISTORY_WINDOW"},
+	{"history_tokens", "HISTORY_TOKENS"},
+	{"keep_alive", "KEEP_ALIVE"},
+	{"warm_up", "WARM_UP"},
+	{"tools_temperature", "TOOLS_TEMPERATURE"},
+	{"tools_repeat_last_n", "TOOLS_REPEAT_LAST_N"},
+	{"tools_repeat_penalty", "TOOLS_REPEAT_PENALTY"},
+	{"max_tool_result_bytes", "MAX_TOOL_RESULT_BYTES"},
+	{"tool_result_chunk_bytes", "TOOL_RESULT_CHUNK_BYTES"},
+	{"tool_error_policy", "TOOL_ERROR_POLICY"},
+	{"tool_call_timeout", "TOOL_CALL_TIMEOUT"},
+	{"tool_call_retries", "TOOL_CALL_RETRIES"},
+	{"session_budget", "SESSION_BUDGET"},
+	{"inline_tool_calls", "INLINE_TOOL_CALLS"},
+	{"inline_max_calls", "INLINE_MAX_CALLS"},
+	{"ascii_icons", "ASCII_ICONS"},
+	{"always_keep_last_tool_result", "ALWAYS_KEEP_LAST_TOOL_RESULT"},
+	{"storage_backend", "STORAGE_BACKEND"},
+	{"save_sessions", "SAVE_SESSIONS"},
+	{"auto_save_interval", "AUTO_SAVE_INTERVAL"},
+	{"message_ttl", "MESSAGE_TTL"},
+}
+
+func loadConfig() Config {
+	config, err := readConfig()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return config
+}
+
+func readConfig() (Config, error) {
+	var config Config
+
+	_ = godotenv.Load()
+
+	yamlFile, err := os.ReadFile("config.yml")
+	if err != nil {
+		return config, fmt.Errorf("failed to read config.yml: %w", err)
+	}
+
+	err = yaml.Unmarshal(yamlFile, &config)
+	if err != nil {
+		return config, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	config.sources = configSources(yamlFile)
+	applyEnvOverrides(&config)
+	applyFlagOverrides(&config)
+
+	switch config.SystemPromptPosition {
+	case "":
+		config.SystemPromptPosition = SystemPromptFirst
+	case SystemPromptFirst, SystemPromptLast:
+	default:
+		return config, fmt.Errorf("invalid system_prompt_position %q: expected %q or %q",
+			config.SystemPromptPosition, SystemPromptFirst, SystemPromptLast)
+	}
+
+	if config.MCP.MaxActiveServers < 0 {
+		return config, fmt.Errorf("invalid mcp.max_active_servers %d: must not be negative", config.MCP.MaxActiveServers)
+	}
+	if config.MCP.InitTimeout == "" {
+		config.MCP.InitTimeout = defaultMCPInitTimeout
+	}
+	if timeout, err := time.ParseDuration(config.MCP.InitTimeout); err != nil || timeout <= 0 {
+		return config, fmt.Errorf("invalid mcp.init_timeout %q: expected a positive duration such as 30s", config.MCP.InitTimeout)
+	}
+
+	switch config.RoleAlternation {
+	case "":
+		config.RoleAlternation = RoleAlternationOff
+	case RoleAlternationOff, RoleAlternationMerge, RoleAlternationSeparator:
+	default:
+		return config, fmt.Errorf("invalid role_alternation %q: expected %q, %q or %q",
+			config.RoleAlternation, RoleAlternationOff, RoleAlternationMerge, RoleAlternationSeparator)
+	}
+
+	if config.source("always_keep_last_tool_result") == sourceDefault {
+		config.AlwaysKeepLastToolResult = config.EnableMCP
+	}
+
+	if config.Language == "auto" {
+		config.Language = languageFromLocale(os.Getenv("LC_ALL"), os.Getenv("LANG"))
+	}
+	if config.Language != "" {
+		if _, ok := languageNames[config.Language]; !ok {
+			return config, fmt.Errorf("unsupported language %q: expected auto or one of %s", config.Language, strings.Join(languageCodes(), ", "))
+		}
+	}
+
+	if err := config.RoleMap.validate(); err != nil {
+		return config, err
+	}
+
+	if config.HistoryWindow == 0 {
+		config.HistoryWindow = MaxConversationMessages
+	}
+	if config.HistoryTokens < 0 {
+		return config, fmt.Errorf("invalid history_tokens %d: must not be negative", config.HistoryTokens)
+	}
+
+	if config.AgentMaxSteps <= 0 {
+		config.AgentMaxSteps = defaultAgentMaxSteps
+	}
+	if config.InlineMaxCalls <= 0 {
+		config.InlineMaxCalls = defaultInlineMaxCalls
+	}
+
+	if config.WarmUpInterval != "" {
+		if interval, err := time.ParseDuration(config.WarmUpInterval); err != nil || interval <= 0 {
+			return config, fmt.Errorf("invalid warm_up_interval %q: expected a positive duration such as 4m", config.WarmUpInterval)
+		}
+	}
+
+	if config.AutoSaveInterval != "" {
+		if interval, err := time.ParseDuration(config.AutoSaveInterval); err != nil || interval < 0 {
+			return config, fmt.Errorf("invalid auto_save_interval %q: expected a duration such as 5m, or 0 to save on exit only", config.AutoSaveInterval)
+		}
+	}
+
+	if config.MessageTTL != "" {
+		if ttl, err := time.ParseDuration(config.MessageTTL); err != nil || ttl < 0 {
+			return config, fmt.Errorf("invalid message_ttl %q: expected a duration such as 24h, or 0 to keep messages", config.MessageTTL)
+		}
+	}
+
+	if config.UserAgent == "" {
+		config.UserAgent = "lloms/" + version
+	}
+
+	if config.NumCtx <= 0 {
+		config.NumCtx = defaultNumCtx
+	}
+
+	switch config.ToolErrorPolicy {
+	case "":
+		config.ToolErrorPolicy = ToolErrorContinue
+	case ToolErrorContinue, ToolErrorAbort, ToolErrorAsk:
+	default:
+		return config, fmt.Errorf("invalid tool_error_policy %q: expected %q, %q or %q",
+			config.ToolErrorPolicy, ToolErrorContinue, ToolErrorAbort, ToolErrorAsk)
+	}
+
+	switch config.StorageBackend {
+	case "":
+		config.StorageBackend = StorageMemory
+	case StorageMemory, StorageSQLite:
+	default:
+		return config, fmt.Errorf("invalid storage_backend %q: expected %q or %q",
+			config.StorageBackend, StorageMemory, StorageSQLite)
+	}
+
+	switch config.SessionTitle {
+	case "":
+		config.SessionTitle = SessionTitleTruncate
+	case SessionTitleTruncate, SessionTitleModel:
+	default:
+		return config, fmt.Errorf("invalid session_title %q: expected %q or %q",
+			config.SessionTitle, SessionTitleTruncate, SessionTitleModel)
+	}
+
+	if err := validateAliases(config.Aliases); err != nil {
+		return config, err
+	}
+	config.resolveModelAliases()
+
+	if err := validateModelTiers(config.ModelTiers); err != nil {
+		return config, err
+	}
+
+	if err := validatePricing(config.Pricing); err != nil {
+		return config, err
+	}
+	if config.SessionBudget < 0 {
+		return config, fmt.Errorf("invalid session_budget %g: must not be negative", config.SessionBudget)
+	}
+
+	if err := config.Retention.validate(); err != nil {
+		return config, err
+	}
+
+	if err := config.ToolTrigger.validate(); err != nil {
+		return config, err
+	}
+
+	if err := validateRedactPatterns(config.Redact); err != nil {
+		return config, err
+	}
+
+	if err := validateToolPostProcessors(config.ToolPostProcessors); err != nil {
+		return config, err
+	}
+
+	if err := validateToolPatterns("tool_summary.tools", config.ToolSummary.Tools); err != nil {
+		return config, err
+	}
+
+	if err := validateToolPatterns("safe_mode_tool_patterns", config.SafeModeToolPatterns); err != nil {
+		return config, err
+	}
+	if err := config.ToolOutputGuard.validate(); err != nil {
+		return config, err
+	}
+	if err := validateToolPatterns("citations.tools", config.Citations.Tools); err != nil {
+		return config, err
+	}
+	if err := validateToolPatterns("confirm_tools", config.ConfirmTools); err != nil {
+		return config, err
+	}
+	if err := validateToolPreviews(config.ToolPreviews); err != nil {
+		return config, err
+	}
+	if err := validateToolLimits(config); err != nil {
+		return config, err
+	}
+
+	if err := config.RefusalRetry.validate(); err != nil {
+		return config, err
+	}
+
+	if config.InlineToolCallPattern == "" {
+		config.InlineToolCallPattern = defaultInlineToolCallPattern
+	}
+	if _, err := regexp.Compile(config.InlineToolCallPattern); err != nil {
+		return config, fmt.Errorf("invalid inline_tool_call_pattern: %w", err)
+	}
+	if config.ToolCallNotePattern == "" {
+		config.ToolCallNotePattern = defaultToolCallNotePattern
+	}
+	if _, err := regexp.Compile(config.ToolCallNotePattern); err != nil {
+		return config, fmt.Errorf("invalid tool_call_note_pattern: %w", err)
+	}
+
+	for i, example := range config.FewShot {
+		switch example.Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return config, fmt.Errorf("invalid role %q in few_shot[%d]: expected %q, %q or %q",
+				example.Role, i, RoleSystem, RoleUser, RoleAssistant)
+		}
+	}
+
+	return config, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return strings.ToLower(value) == "true"
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var result float64
+	_, err := fmt.Sscanf(value, "%f", &result)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var result int
+	_, err := fmt.Sscanf(value, "%d", &result)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+// augmentUserMessage wraps a user message with the configured prefix and
+// suffix.
+func (config Config) augmentUserMessage(input string) string {
+	parts := []string{input}
+	if config.UserMessagePrefix != "" {
+		parts = append([]string{config.UserMessagePrefix}, parts...)
+	}
+	if config.UserMessageSuffix != "" {
+		parts = append(parts, config.UserMessageSuffix)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// configField returns the field of config whose yaml name is key.
+func configField(config reflect.Value, key string) (reflect.Value, bool) {
+	configType := config.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		if configType.Field(i).Tag.Get("yaml") == key {
+			return config.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func applyEnvOverrides(config *Config) {
+	value := reflect.ValueOf(config).Elem()
+	for _, override := range envOverrides {
+		if os.Getenv(override.env) == "" {
+			continue
+		}
+		field, ok := configField(value, override.key)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(getEnv(override.env, field.String()))
+		case reflect.Bool:
+			field.SetBool(getEnvBool(override.env, field.Bool()))
+		case reflect.Float64:
+			field.SetFloat(getEnvFloat(override.env, field.Float()))
+		case reflect.Int:
+			field.SetInt(int64(getEnvInt(override.env, int(field.Int()))))
+		}
+		config.sources[override.key] = sourceEnv
+	}
+}
+
+// flagOverrides holds the config values forced by command line flags, keyed
+// by yaml name. They win over config.yml and the environment, also on
+// /reload.
+var flagOverrides = map[string]any{}
+
+func applyFlagOverrides(config *Config) {
+	value := reflect.ValueOf(config).Elem()
+	for key, override := range flagOverrides {
+		field, ok := configField(value, key)
+		if !ok || reflect.TypeOf(override) != field.Type() {
+			continue
+		}
+		field.Set(reflect.ValueOf(override))
+		config.sources[key] = sourceFlag
+	}
+}
+
+// configSources marks every top level key present in the YAML file.
+func configSources(yamlFile []byte) map[string]string {
+	sources := make(map[string]string)
+	var keys map[string]any
+	if err := yaml.Unmarshal(yamlFile, &keys); err == nil {
+		for key := range keys {
+			sources[key] = sourceYAML
+		}
+	}
+	return sources
+}
+
+func (config Config) source(key string) string {
+	if source, ok := config.sources[key]; ok {
+		return source
+	}
+	return sourceDefault
+}
+
+// apiURL is the URL the Ollama API paths (/api/...) are appended to:
+// ollama_url followed by api_base_path.
+func (config Config) apiURL() string {
+	base := strings.TrimRight(config.OllamaURL, "/")
+	if path := strings.Trim(config.APIBasePath, "/"); path != "" {
+		base += "/" + path
+	}
+	return base
+}
+
+// historyWindow describes how much history is sent with each request.
+func (config Config) historyWindow() string {
+	switch {
+	case config.HistoryTokens > 0:
+		return fmt.Sprintf("%d tokens", config.HistoryTokens)
+	case config.HistoryWindow < 0:
+		return "all messages"
+	}
+	return fmt.Sprintf("%d messages", config.HistoryWindow)
+}
+
+// redacted returns a copy of the config that is safe to print.
+func (config Config) redacted() Config {
+	if proxyURL, err := url.Parse(config.HTTPProxy); err == nil && config.HTTPProxy != "" {
+		config.HTTPProxy = proxyURL.Redacted()
+	}
+	if len(config.Headers) > 0 {
+		headers := make(map[string]string, len(config.Headers))
+		for name := range config.Headers {
+			headers[name] = "xxxxx"
+		}
+		config.Headers = headers
+	}
+	return config
+}
+
+// formatConfig renders the config as YAML, annotating every top level key
+// with the source of its value.
+func formatConfig(config Config) (string, error) {
+	out, err := yaml.Marshal(config.redacted())
+	if err != nil {
+		return "", err
+	}
+
+	var formatted strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		formatted.WriteString(line)
+		if key, _, found := strings.Cut(line, ":"); found && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			formatted.WriteString("  # " + config.source(key))
+		}
+		formatted.WriteString("\n")
+	}
+	return formatted.String(), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+func (app *App) configCommand(args []string) {
+	switch {
+	case len(args) == 0:
+		app.showConfig()
+	case args[0] == "diff" && len(args) == 1:
+		app.diffConfig()
+	case args[0] == "save" && len(args) == 1:
+		app.saveConfig()
+	default:
+		systemColor.Println("Usage: /config [diff|save]")
+	}
+}
+
+// configChanges returns the config read from config.yml and the top level
+// keys whose live value differs from it, e.g. after /set or /model.
+func (app *App) configChanges() (Config, []string, bool) {
+	onDisk, err := readConfig()
+	if err != nil {
+		systemColor.Printf("Failed to read config.yml: %v\n", err)
+		return Config{}, nil, false
+	}
+	return onDisk, changedConfigFields(onDisk, app.config), true
+}
+
+// diffConfig prints the keys whose live value differs from config.yml,
+// with secrets redacted.
+func (app *App) diffConfig() {
+	onDisk, changed, ok := app.configChanges()
+	if !ok {
+		return
+	}
+	if len(changed) == 0 {
+		systemColor.Println("The live config matches config.yml.")
+		return
+	}
+	diskValues := reflect.ValueOf(onDisk.redacted())
+	liveValues := reflect.ValueOf(app.config.redacted())
+	for _, key := range changed {
+		diskField, _ := configField(diskValues, key)
+		liveField, _ := configField(liveValues, key)
+		systemColor.Printf("%s:\n", key)
+		systemColor.Printf("  - %s\n", indentLines(configValueText(diskField), "    "))
+		assistantColor.Printf("  + %s\n", indentLines(configValueText(liveField), "    "))
+	}
+}
+
+// saveConfig writes the live values that differ from config.yml back to
+// it. Only single line values can be saved this way; the others are
+// listed so they can be edited by hand.
+func (app *App) saveConfig() {
+	_, changed, ok := app.configChanges()
+	if !ok {
+		return
+	}
+	if len(changed) == 0 {
+		systemColor.Println("Nothing to save: the live config matches config.yml.")
+		return
+	}
+	liveValues := reflect.ValueOf(app.config)
+	var saved, skipped []string
+	for _, key := range changed {
+		field, _ := configField(liveValues, key)
+		text := configValueText(field)
+		if strings.Contains(text, "\n") || field.Kind() == reflect.Map || field.Kind() == reflect.Slice || field.Kind() == reflect.Struct {
+			skipped = append(skipped, key)
+			continue
+		}
+		if err := saveConfigValue("config.yml", key, text); err != nil {
+			systemColor.Printf("Failed to save %s to config.yml: %v\n", key, err)
+			return
+		}
+		saved = append(saved, key)
+	}
+	if len(saved) > 0 {
+		systemColor.Printf("Saved to config.yml: %s\n", strings.Join(saved, ", "))
+	}
+	if len(skipped) > 0 {
+		systemColor.Printf("Not saved, edit config.yml by hand: %s\n", strings.Join(skipped, ", "))
+	}
+}
+
+// indentLines indents every line of text but the first.
+func indentLines(text, indent string) string {
+	return strings.ReplaceAll(text, "\n", "\n"+indent)
+}
+
+// configValueText renders a config value as it would appear in YAML.
+func configValueText(field reflect.Value) string {
+	out, err := yaml.Marshal(field.Interface())
+	if err != nil {
+		return "?"
+	}
+	return strings.TrimRight(string(out), "\n")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const defaultContinuePrompt = "Your previous answer was cut off. Continue exactly where it stopped, without repeating anything."
+
+// errInterrupted stops a chat stream when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// savePartialResponse keeps the text streamed before a failure in the
+// history so the answer can be resumed with /continue. It is redacted like
+// a complete answer.
+func (app *App) savePartialResponse(response string, cause error) error {
+	if app.config.RedactHistory {
+		response = newRedactor(app.config.Redact).redact(response)
+	}
+	err := app.conversation.Save(generateMsgID(), llm.Message{Role: RoleAssistant, Content: response})
+	if err != nil {
+		return fmt.Errorf("failed to save partial response: %w", err)
+	}
+	app.canContinue = true
+	return fmt.Errorf("%w: response %v, the partial answer was kept; use /continue to resume it", errTurnAborted, cause)
+}
+
+func (app *App) continueResponse() {
+	if !app.canContinue {
+		systemColor.Println("There is no interrupted answer to continue.")
+		return
+	}
+	prompt := app.config.ContinuePrompt
+	if prompt == "" {
+		prompt = defaultContinuePrompt
+	}
+	if err := app.processTurn(prompt); err != nil {
+		systemColor.Printf("Continue failed: %v\n", err)
+	}
+}
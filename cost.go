@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// ModelPrice is the price of a model per 1K tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input"`
+	Output float64 `yaml:"output"`
+}
+
+// cost estimates the price of the tokens used by answer.
+func (price ModelPrice) cost(answer llm.Answer) float64 {
+	return float64(answer.PromptEvalCount)/1000*price.Input + float64(answer.EvalCount)/1000*price.Output
+}
+
+// turnUsage adds up the tokens and estimated cost of every request sent
+// during a turn.
+type turnUsage struct {
+	promptTokens     int
+	completionTokens int
+	cost             float64
+}
+
+func validatePricing(pricing map[string]ModelPrice) error {
+	for model, price := range pricing {
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("invalid pricing for %q: prices must not be negative", model)
+		}
+	}
+	return nil
+}
+
+// price looks up the pricing entry of model, ignoring the implicit
+// ":latest" tag.
+func (config Config) price(model string) (ModelPrice, bool) {
+	if price, ok := config.Pricing[model]; ok {
+		return price, true
+	}
+	for name, price := range config.Pricing {
+		if normalizeModelName(name) == normalizeModelName(model) {
+			return price, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// addUsage adds the token counts of answer to the current turn and, for
+// priced models, its estimated cost to the turn and session totals.
+func (app *App) addUsage(model string, answer llm.Answer) {
+	app.usage.promptTokens += answer.PromptEvalCount
+	app.usage.completionTokens += answer.EvalCount
+
+	price, ok := app.config.price(model)
+	if !ok {
+		return
+	}
+	cost := price.cost(answer)
+	app.usage.cost += cost
+	app.sessionCost += cost
+}
+
+// reportUsage prints the turn's token counts and cost with --stats, and
+// warns once when the session goes over its budget.
+func (app *App) reportUsage() {
+	if app.showStats {
+		stats := fmt.Sprintf("Tokens: %d in, %d out", app.usage.promptTokens, app.usage.completionTokens)
+		if len(app.config.Pricing) > 0 {
+			stats += fmt.Sprintf(" | cost: $%.4f (session $%.4f)", app.usage.cost, app.sessionCost)
+		}
+		systemColor.Println(stats)
+	}
+	if app.config.SessionBudget > 0 && app.sessionCost > app.config.SessionBudget && !app.budgetWarned {
+		app.budgetWarned = true
+		systemColor.Printf("Warning: Estimated session cost $%.4f is over the $%.2f budget.\n", app.sessionCost, app.config.SessionBudget)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const (
+	defaultDetachPrompt = "Continue working on the task."
+	detachedDoneMarker  = "--- detached run finished ---"
+	attachPollInterval  = 500 * time.Millisecond
+)
+
+// detachedFlags are the command line flags passed on to the background
+// run, so it calls the same tools under the same rules as the foreground.
+var detachedFlags = []string{"mcp", "no-mcp", "safe", "pick-server", "progress-file", "thinking-file", "verbose"}
+
+func detachedLogPath(config Config, id string) string {
+	return filepath.Join(sessionsDir(config), id+".log")
+}
+
+// detachedPIDPath is where the pid of a background run is kept, so attach
+// can tell when it exited without reaching the done marker.
+func detachedPIDPath(config Config, id string) string {
+	return filepath.Join(sessionsDir(config), id+".pid")
+}
+
+// detach saves the conversation and hands it to a background lloms
+// process that runs one more agent turn with prompt, logging its output.
+// The foreground then starts over with a new session.
+func (app *App) detach(prompt string) {
+	if !app.config.AgentMode {
+		systemColor.Println("/detach needs agent_mode to be enabled.")
+		return
+	}
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		prompt = defaultDetachPrompt
+	}
+
+	// The background run owns this session file from now on; the
+	// foreground moves on to a new ID so the two never write the same file.
+	id := app.sessionID
+	if err := app.saveSession(); err != nil {
+		systemColor.Printf("Failed to save the session: %v\n", err)
+		return
+	}
+	sessionPath := filepath.Join(sessionsDir(app.config), id+".json")
+	if _, err := os.Stat(sessionPath); err != nil {
+		// Nothing was said yet: start the run from an empty session.
+		if err := writeSessionFile(sessionPath, sessionFile{ID: id, Model: app.config.ChatModel, CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+			systemColor.Printf("Failed to save the session: %v\n", err)
+			return
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		systemColor.Printf("Failed to find the lloms executable: %v\n", err)
+		return
+	}
+	logFile, err := os.OpenFile(detachedLogPath(app.config, id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		systemColor.Printf("Failed to open the log file: %v\n", err)
+		return
+	}
+	defer logFile.Close()
+
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		if slices.Contains(detachedFlags, f.Name) {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	args = append(args, "detached", sessionPath, prompt)
+	cmd := exec.Command(executable, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		systemColor.Printf("Failed to start the background run: %v\n", err)
+		return
+	}
+	if err := os.WriteFile(detachedPIDPath(app.config, id), []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+		systemColor.Printf("Warning: Failed to save the pid of the background run: %v\n", err)
+	}
+	// The child may outlive us; until then, reap it when it exits so attach
+	// does not see it as still running.
+	go cmd.Wait()
+
+	systemColor.Printf("Detached session %s. Follow it with: %s attach %s\n", id, filepath.Base(executable), id)
+	if err := app.startNewSession(); err != nil {
+		systemColor.Printf("Failed to start a new session: %v\n", err)
+		return
+	}
+	systemColor.Println("Started a new session.")
+}
+
+// loadSession replaces the conversation with a saved session.
+func (app *App) loadSession(session sessionFile) error {
+	app.sessionID = session.ID
+	app.sessionTitleText = session.Title
+	app.vars = session.Vars
+	for i, message := range session.Messages {
+		if message.Role == RoleSystem {
+			continue
+		}
+		id := generateMsgID()
+		if err := app.conversation.Save(id, llm.Message{Role: message.Role, Content: message.Content}); err != nil {
+			return err
+		}
+		if slices.Contains(session.Pins, i) {
+			if app.pins == nil {
+				app.pins = map[string]bool{}
+			}
+			app.pins[id] = true
+		}
+		if tags, ok := session.Tags[i]; ok {
+			if app.tags == nil {
+				app.tags = map[string][]string{}
+			}
+			app.tags[id] = tags
+		}
+	}
+	return nil
+}
+
+// runDetached is the background side of /detach: it runs the turn and
+// saves the session back.
+func (app *App) runDetached(prompt string) error {
+	systemColor.Printf("Detached run started at %s\nYou: %s\n", time.Now().Format(time.RFC3339), prompt)
+	app.sessionMu.Lock()
+	defer app.sessionMu.Unlock()
+	err := app.processTurn(prompt)
+	if saveErr := app.saveSession(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
+// attach prints the log of a detached session and follows it until the
+// background run finishes, or exits without finishing.
+func attach(config Config, id string) error {
+	id = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(id), ".json"), ".log")
+	file, err := os.Open(detachedLogPath(config, id))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	// Logs of runs started before the pid was saved are followed until the
+	// marker only.
+	pid := 0
+	if data, err := os.ReadFile(detachedPIDPath(config, id)); err == nil {
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	exited := false
+
+	var tail []byte
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			os.Stdout.Write(buf[:n])
+			tail = append(tail, buf[:n]...)
+			if bytes.Contains(tail, []byte(detachedDoneMarker)) {
+				return nil
+			}
+			if len(tail) > len(detachedDoneMarker) {
+				tail = tail[len(tail)-len(detachedDoneMarker):]
+			}
+		}
+		if err == io.EOF {
+			// Read once more after the run is gone, for what it wrote last.
+			if exited {
+				return fmt.Errorf("the background run exited without finishing")
+			}
+			exited = pid > 0 && !processRunning(pid)
+			if !exited {
+				time.Sleep(attachPollInterval)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// recordExchange keeps the last query sent and answer received for /last.
+func (app *App) recordExchange(query llm.Query, answer llm.Answer) {
+	if query.TokenHeaderValue != "" {
+		query.TokenHeaderValue = "xxxxx"
+	}
+	app.lastQuery = query
+	app.lastResponse = answer
+}
+
+func (app *App) showLastExchange() {
+	if app.lastQuery.Model == "" {
+		systemColor.Println("No request has been sent yet.")
+		return
+	}
+	for _, part := range []struct {
+		title string
+		value any
+	}{{"Request", app.lastQuery}, {"Response", app.lastResponse}} {
+		data, err := json.MarshalIndent(part.value, "", "  ")
+		if err != nil {
+			systemColor.Printf("Failed to format %s: %v\n", part.title, err)
+			continue
+		}
+		systemColor.Printf("%s:\n", part.title)
+		fmt.Fprintln(app.out, string(data))
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// htmlThemes holds the page colors and code style of each theme.
+var htmlThemes = map[string]struct {
+	background, text, muted, user, assistant, tool, border string
+	codeStyle                                              string
+}{
+	ThemeLight: {"#ffffff", "#1f2328", "#656d76", "#ddf4ff", "#f6f8fa", "#fff8c5", "#d0d7de", "github"},
+	ThemeDark:  {"#0d1117", "#e6edf3", "#8d96a0", "#0c2d6b", "#161b22", "#3b2e00", "#30363d", "github-dark"},
+}
+
+var inlineCodePattern = regexp.MustCompile("`([^`\n]+)`")
+
+// exportCommand handles "/export html <file> [--theme light|dark]".
+func (app *App) exportCommand(args []string) {
+	theme := ThemeLight
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--theme" && i+1 < len(args) {
+			theme = args[i+1]
+			i++
+			continue
+		}
+		rest = append(rest, args[i])
+	}
+	if len(rest) != 2 || rest[0] != "html" {
+		systemColor.Println("Usage: /export html <file> [--theme light|dark]")
+		return
+	}
+	if _, ok := htmlThemes[theme]; !ok {
+		systemColor.Printf("Invalid theme %q: expected %q or %q\n", theme, ThemeLight, ThemeDark)
+		return
+	}
+
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	messages := messagesOf(records)
+	title := app.sessionTitleText
+	if title == "" {
+		title = "LLoms conversation"
+	}
+	page := sessionHTML(title, app.config.ChatModel, messages, theme)
+	if err := os.WriteFile(rest[1], []byte(page), 0644); err != nil {
+		systemColor.Printf("Failed to write %s: %v\n", rest[1], err)
+		return
+	}
+	systemColor.Printf("Exported %d messages to %s.\n", len(messages), rest[1])
+}
+
+// sessionHTML renders messages as a self-contained HTML page: styles and
+// highlighted code are inlined and tool results are collapsed.
+func sessionHTML(title, model string, messages []llm.Message, theme string) string {
+	colors := htmlThemes[theme]
+	var b strings.Builder
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>%s</title>
+<style>
+body { background: %s; color: %s; font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; line-height: 1.5; max-width: 860px; margin: 2rem auto; padding: 0 1rem; }
+header p { color: %s; }
+section { border: 1px solid %s; border-radius: 8px; padding: 0.5rem 1rem; margin: 1rem 0; }
+section.user { background: %s; }
+section.assistant, section.system { background: %s; }
+section.tool { background: %s; }
+h2 { font-size: 0.85rem; text-transform: uppercase; letter-spacing: 0.05em; color: %s; margin: 0.25rem 0; }
+.text { white-space: pre-wrap; overflow-wrap: anywhere; }
+pre { padding: 0.75rem; border-radius: 6px; overflow-x: auto; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 0.9em; }
+summary { cursor: pointer; color: %s; }
+</style>
+</head>
+<body>
+<header>
+<h1>%s</h1>
+<p>%s, exported %s</p>
+</header>
+`, html.EscapeString(title), colors.background, colors.text, colors.muted, colors.border,
+		colors.user, colors.assistant, colors.tool, colors.muted, colors.muted,
+		html.EscapeString(title), html.EscapeString(model), time.Now().Format("2006-01-02 15:04"))
+
+	for i := 0; i < len(messages); i++ {
+		role := viewRole(messages, i)
+		if strings.TrimSpace(messages[i].Content) == "" {
+			continue
+		}
+		if role != roleTool {
+			fmt.Fprintf(&b, "<section class=\"%s\">\n<h2>%s</h2>\n%s</section>\n",
+				html.EscapeString(role), html.EscapeString(role), messageHTML(messages[i].Content, colors.codeStyle))
+			continue
+		}
+		// A tool call is its note followed by one or more result parts.
+		summary := strings.TrimSpace(messages[i].Content)
+		var body strings.Builder
+		for i+1 < len(messages) && messages[i+1].Role == RoleUser &&
+			(body.Len() == 0 || strings.HasPrefix(messages[i+1].Content, "[part ")) {
+			i++
+			body.WriteString(messageHTML(messages[i].Content, colors.codeStyle))
+		}
+		fmt.Fprintf(&b, "<section class=\"tool\">\n<details>\n<summary>%s</summary>\n%s</details>\n</section>\n",
+			html.EscapeString(summary), body.String())
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// messageHTML renders message text, highlighting its fenced code blocks.
+func messageHTML(text, codeStyle string) string {
+	var b strings.Builder
+	var prose, code []string
+	language := ""
+	inCode := false
+	flushProse := func() {
+		if content := strings.TrimSpace(strings.Join(prose, "\n")); content != "" {
+			escaped := inlineCodePattern.ReplaceAllString(html.EscapeString(content), "<code>$1</code>")
+			fmt.Fprintf(&b, "<div class=\"text\">%s</div>\n", escaped)
+		}
+		prose = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inCode && strings.HasPrefix(trimmed, "```"):
+			flushProse()
+			language, _, _ = strings.Cut(strings.TrimSpace(strings.TrimPrefix(trimmed, "```")), " ")
+			inCode, code = true, nil
+		case inCode && trimmed == "```":
+			b.WriteString(highlightCode(strings.Join(code, "\n"), language, codeStyle))
+			inCode = false
+		case inCode:
+			code = append(code, line)
+		default:
+			prose = append(prose, line)
+		}
+	}
+	if inCode {
+		b.WriteString(highlightCode(strings.Join(code, "\n"), language, codeStyle))
+	}
+	flushProse()
+	return b.String()
+}
+
+// highlightCode renders code as a <pre> block with inline styles, falling
+// back to plain escaped text when highlighting fails.
+func highlightCode(code, language, codeStyle string) string {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err == nil {
+		var b strings.Builder
+		formatter := chromahtml.New(chromahtml.WithClasses(false))
+		if err = formatter.Format(&b, styles.Get(codeStyle), iterator); err == nil {
+			return b.String() + "\n"
+		}
+	}
+	return "<pre><code>" + html.EscapeString(code) + "</code></pre>\n"
+}
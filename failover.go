@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// failoverTransport sends requests meant for any of the Ollama hosts to the
+// one that last answered, trying the next host when it cannot be reached
+// or reports itself unavailable.
+type failoverTransport struct {
+	base  http.RoundTripper
+	hosts []*url.URL
+
+	mu      sync.Mutex
+	current int
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.handles(req.URL) {
+		return t.base.RoundTrip(req)
+	}
+	t.mu.Lock()
+	start := t.current
+	t.mu.Unlock()
+
+	for n := range t.hosts {
+		host := t.hosts[(start+n)%len(t.hosts)]
+		attempt := req.Clone(req.Context())
+		attempt.URL.Scheme, attempt.URL.Host, attempt.Host = host.Scheme, host.Host, ""
+		if n > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attempt)
+		failed := err != nil || hostUnavailable(resp.StatusCode)
+		last := n == len(t.hosts)-1 || req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil)
+		if !failed || last {
+			if !failed && n > 0 {
+				t.mu.Lock()
+				t.current = (start + n) % len(t.hosts)
+				t.mu.Unlock()
+				systemColor.Printf("Switched to Ollama host %s.\n", host.Host)
+			}
+			return resp, err
+		}
+		if err == nil {
+			err = fmt.Errorf("returned %s", resp.Status)
+			resp.Body.Close()
+		}
+		systemColor.Printf("Warning: Ollama host %s failed (%v), trying the next one...\n", host.Host, err)
+	}
+	panic("unreachable")
+}
+
+// handles reports whether u points at one of the Ollama hosts.
+func (t *failoverTransport) handles(u *url.URL) bool {
+	for _, host := range t.hosts {
+		if u.Scheme == host.Scheme && u.Host == host.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// hostUnavailable reports whether status means the host, rather than the
+// request, is at fault.
+func hostUnavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// ollamaHosts parses ollama_url followed by the ollama_urls fallbacks.
+func ollamaHosts(config Config) ([]*url.URL, error) {
+	primary, err := url.Parse(config.OllamaURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ollama_url %q: %w", config.OllamaURL, err)
+	}
+	hosts := []*url.URL{primary}
+	for _, raw := range config.OllamaURLs {
+		host, err := url.Parse(raw)
+		if err != nil || host.Scheme == "" || host.Host == "" {
+			return nil, fmt.Errorf("invalid ollama_urls entry %q: expected a URL such as http://localhost:11434", raw)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
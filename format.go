@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ResponseFormatText = "text"
+	ResponseFormatJSON = "json"
+	ResponseFormatYAML = "yaml"
+)
+
+const yamlFormatInstruction = "Answer only with a valid YAML document, without prose or code fences."
+
+// responseFormat is the answer format set with /format. The zero value is
+// plain text.
+type responseFormat struct {
+	name string
+	// schema and schemaFile are set for json answers following a schema.
+	schema     map[string]any
+	schemaFile string
+}
+
+func (format responseFormat) String() string {
+	switch {
+	case format.name == "":
+		return ResponseFormatText
+	case format.schemaFile != "":
+		return format.name + " (" + format.schemaFile + ")"
+	}
+	return format.name
+}
+
+// query returns the value of the chat query's Format: "json", the schema
+// or nothing. Ollama has no yaml mode, so yaml relies on the instruction
+// added to the system prompt.
+func (format responseFormat) query() any {
+	if format.name != ResponseFormatJSON {
+		return nil
+	}
+	if format.schema != nil {
+		return format.schema
+	}
+	return "json"
+}
+
+func (format responseFormat) instruction() string {
+	if format.name == ResponseFormatYAML {
+		return yamlFormatInstruction
+	}
+	return ""
+}
+
+func (app *App) formatCommand(args []string) {
+	switch {
+	case len(args) == 0:
+		systemColor.Printf("Response format: %s\n", app.responseFormat)
+		return
+	case args[0] == ResponseFormatText && len(args) == 1:
+		app.responseFormat = responseFormat{}
+	case args[0] == ResponseFormatYAML && len(args) == 1:
+		app.responseFormat = responseFormat{name: ResponseFormatYAML}
+	case args[0] == ResponseFormatJSON && len(args) <= 2:
+		format := responseFormat{name: ResponseFormatJSON}
+		if len(args) == 2 {
+			schema, err := loadSchema(args[1])
+			if err != nil {
+				systemColor.Printf("Invalid schema %s: %v\n", args[1], err)
+				return
+			}
+			format.schema = schema
+			format.schemaFile = args[1]
+		}
+		app.responseFormat = format
+	default:
+		systemColor.Println("Usage: /format [json [schemafile]|yaml|text]")
+		return
+	}
+	systemColor.Printf("Response format set to %s.\n", app.responseFormat)
+}
+
+// loadSchema reads a JSON schema and checks the parts the answers are
+// validated against.
+func loadSchema(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("not a JSON object: %w", err)
+	}
+	if schemaType, ok := schema["type"]; ok {
+		if _, ok := schemaType.(string); !ok {
+			return nil, fmt.Errorf("type must be a string")
+		}
+	}
+	properties := map[string]any{}
+	if value, ok := schema["properties"]; ok {
+		if properties, ok = value.(map[string]any); !ok {
+			return nil, fmt.Errorf("properties must be an object")
+		}
+	}
+	if value, ok := schema["required"]; ok {
+		required, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("required must be a list")
+		}
+		for _, item := range required {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("required must list property names")
+			}
+			if _, ok := properties[name]; !ok {
+				return nil, fmt.Errorf("required property %q is not in properties", name)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// check reports why an answer does not match the response
+// format, or "" if it does.
+func (format responseFormat) check(answer string) string {
+	switch format.name {
+	case ResponseFormatJSON:
+		var value any
+		if err := json.Unmarshal([]byte(answer), &value); err != nil {
+			return fmt.Sprintf("the answer is not valid JSON: %v", err)
+		}
+		if format.schema == nil {
+			return ""
+		}
+		if schemaType, _ := format.schema["type"].(string); schemaType == "object" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return "the answer is not a JSON object"
+			}
+			required, _ := format.schema["required"].([]any)
+			for _, name := range required {
+				if _, ok := object[name.(string)]; !ok {
+					return fmt.Sprintf("the answer lacks the required property %q", name)
+				}
+			}
+		}
+	case ResponseFormatYAML:
+		answer = strings.TrimSpace(answer)
+		answer = strings.TrimPrefix(answer, "```yaml")
+		answer = strings.TrimSuffix(answer, "```")
+		var value any
+		if err := yaml.Unmarshal([]byte(answer), &value); err != nil {
+			return fmt.Sprintf("the answer is not valid YAML: %v", err)
+		}
+	}
+	return ""
+}
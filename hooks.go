@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+type OnStartConfig struct {
+	Command      string `yaml:"command"`
+	InjectOutput bool   `yaml:"inject_output"`
+	Background   bool   `yaml:"background"`
+}
+
+// shellCommand builds a command run through the platform shell.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// runShell runs command with input on stdin and returns its stdout. Stderr
+// is included in the error when the command fails.
+func runShell(command, input string) (string, error) {
+	cmd := shellCommand(command)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// runOnStart runs the configured startup command. Background commands are
+// started and left running; otherwise the output can be injected into the
+// system prompt as context.
+func (app *App) runOnStart() {
+	hook := app.config.OnStart
+	if hook.Command == "" {
+		return
+	}
+
+	if hook.Background {
+		cmd := shellCommand(hook.Command)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			systemColor.Printf("Warning: on_start command failed: %v\n", err)
+			return
+		}
+		systemColor.Printf("Started on_start command (pid %d)\n", cmd.Process.Pid)
+		go cmd.Wait()
+		return
+	}
+
+	systemColor.Printf("Running on_start command: %s\n", hook.Command)
+	output, err := runShell(hook.Command, "")
+	if err != nil {
+		systemColor.Printf("Warning: on_start command failed: %v\n", err)
+		return
+	}
+	if !hook.InjectOutput || strings.TrimSpace(output) == "" {
+		return
+	}
+
+	app.startupContext = fmt.Sprintf("Output of the startup command `%s`:\n%s", hook.Command, output)
+}
+
+// filterInput pipes a user message through input_filter and returns its
+// output. The turn is aborted when the command fails or prints nothing.
+func (app *App) filterInput(userInput string) (string, error) {
+	if app.config.InputFilter == "" {
+		return userInput, nil
+	}
+	output, err := runShell(app.config.InputFilter, userInput)
+	if err != nil {
+		return "", fmt.Errorf("%w: input_filter failed, message not sent: %v", errTurnAborted, err)
+	}
+	output = strings.TrimRight(output, "\r\n")
+	if strings.TrimSpace(output) == "" {
+		return "", fmt.Errorf("%w: input_filter printed nothing, message not sent", errTurnAborted)
+	}
+	return output, nil
+}
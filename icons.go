@@ -0,0 +1,18 @@
+package main
+
+// Icons prefixed to status lines. Some terminals render emoji at a
+// different width than expected, so ascii_icons swaps them for plain text.
+var (
+	iconTool    = "🛠️"
+	iconAgent   = "🔁"
+	iconBot     = "🤖"
+	iconBlocked = "🚫"
+)
+
+func setASCIIIcons(ascii bool) {
+	if ascii {
+		iconTool, iconAgent, iconBot, iconBlocked = "[tool]", "[agent]", "[lloms]", "[blocked]"
+		return
+	}
+	iconTool, iconAgent, iconBot, iconBlocked = "🛠️", "🔁", "🤖", "🚫"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// openAIConversation is the shape of a conversation in a ChatGPT data
+// export (conversations.json).
+type openAIConversation struct {
+	Title       string                       `json:"title"`
+	UpdateTime  float64                      `json:"update_time"`
+	CurrentNode string                       `json:"current_node"`
+	Mapping     map[string]openAIMappingNode `json:"mapping"`
+}
+
+type openAIMappingNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		Content struct {
+			Parts []any `json:"parts"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// thread walks back from the current node to the root and returns the
+// messages of the active branch in order.
+func (c openAIConversation) thread() []llm.Message {
+	var messages []llm.Message
+	for id := c.CurrentNode; id != ""; id = c.Mapping[id].Parent {
+		node, ok := c.Mapping[id]
+		if !ok {
+			break
+		}
+		if node.Message == nil {
+			continue
+		}
+		var parts []string
+		for _, part := range node.Message.Content.Parts {
+			if text, ok := part.(string); ok && text != "" {
+				parts = append(parts, text)
+			}
+		}
+		messages = append(messages, llm.Message{Role: node.Message.Author.Role, Content: strings.Join(parts, "\n")})
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages
+}
+
+type simpleMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// parseTranscript accepts a ChatGPT export (a single conversation or the
+// whole conversations.json, in which case the most recently updated one is
+// used), a plain [{role, content}] array or an object with a "messages"
+// array of that shape.
+func parseTranscript(data []byte) ([]llm.Message, string, error) {
+	var conversations []openAIConversation
+	if err := json.Unmarshal(data, &conversations); err == nil && len(conversations) > 0 && conversations[0].Mapping != nil {
+		sort.Slice(conversations, func(i, j int) bool { return conversations[i].UpdateTime > conversations[j].UpdateTime })
+		return conversations[0].thread(), conversations[0].Title, nil
+	}
+
+	var conversation openAIConversation
+	if err := json.Unmarshal(data, &conversation); err == nil && conversation.Mapping != nil {
+		return conversation.thread(), conversation.Title, nil
+	}
+
+	var simple []simpleMessage
+	if err := json.Unmarshal(data, &simple); err != nil {
+		var wrapped struct {
+			Messages []simpleMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil || wrapped.Messages == nil {
+			return nil, "", fmt.Errorf("unrecognised transcript format")
+		}
+		simple = wrapped.Messages
+	}
+
+	messages := make([]llm.Message, 0, len(simple))
+	for _, message := range simple {
+		messages = append(messages, llm.Message{Role: message.Role, Content: message.Content})
+	}
+	return messages, "", nil
+}
+
+// normalizeRole maps the role names used by other tools onto ours.
+func normalizeRole(role string) string {
+	switch strings.ToLower(role) {
+	case RoleSystem, "developer":
+		return RoleSystem
+	case RoleAssistant, "ai", "bot", "model", "gpt":
+		return RoleAssistant
+	default:
+		return RoleUser
+	}
+}
+
+// importTranscript loads a transcript file into the current conversation
+// and returns the number of messages imported.
+func (app *App) importTranscript(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	messages, title, err := parseTranscript(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if title != "" {
+		systemColor.Printf("Importing conversation %q\n", title)
+	}
+
+	imported := 0
+	for _, message := range messages {
+		if strings.TrimSpace(message.Content) == "" {
+			continue
+		}
+		message.Role = normalizeRole(message.Role)
+		if err := app.conversation.Save(generateMsgID(), message); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+	return imported, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// injectedTag marks the messages added by /inject, so that they can be
+// told apart from real tool results with /find and in saved sessions.
+const injectedTag = "injected"
+
+// injectCommand handles "/inject tool <name> <result>": it records result
+// as if the tool had returned it and lets the chat model answer. The tool
+// is not called and does not need to exist.
+func (app *App) injectCommand(args string) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+	name, result, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	result = strings.TrimSpace(result)
+	if kind != "tool" || name == "" || result == "" {
+		systemColor.Println("Usage: /inject tool <name> <result>")
+		return
+	}
+	if !app.turnMu.TryLock() {
+		systemColor.Printf("%v\n", errTurnBusy)
+		return
+	}
+	saved := app.saveInjectedResult(name, result)
+	app.turnMu.Unlock()
+	if !saved {
+		return
+	}
+
+	if _, err := app.runTurn(app.ctx, "", "", turnInjected); err != nil {
+		systemColor.Printf("Failed to answer the injected result: %v\n", err)
+	}
+}
+
+// saveInjectedResult adds result to the conversation as if tool name had
+// returned it and tags the new messages as injected.
+func (app *App) saveInjectedResult(name, result string) bool {
+	before, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return false
+	}
+	toolColor.Printf("%s Injected result for tool: %s\n", iconTool, name)
+	err = app.conversation.Save(generateMsgID(), llm.Message{
+		Role:    RoleAssistant,
+		Content: fmt.Sprintf(toolUsedFormat, name),
+	})
+	if err != nil {
+		systemColor.Printf("Failed to save injected tool call: %v\n", err)
+		return false
+	}
+	app.recordToolResult(nil, name, result)
+
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return false
+	}
+	if app.tags == nil {
+		app.tags = map[string][]string{}
+	}
+	for _, record := range records[len(before):] {
+		app.tags[record.Id] = append(app.tags[record.Id], injectedTag)
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// defaultInlineToolCallPattern matches the <tool_call>{...}</tool_call>
+// blocks many instruction-tuned models emit when they have no native tools
+// API.
+const defaultInlineToolCallPattern = `(?s)<tool_call>\s*(\{.*?\})\s*</tool_call>`
+
+// errInlineToolCall stops the chat stream once a tool call has been found.
+var errInlineToolCall = errors.New("inline tool call")
+
+// inlineToolCall is a tool call the chat model wrote into its answer.
+type inlineToolCall struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+// findInlineToolCall looks for a tool call in text. The first capture group
+// of pattern, or the whole match when there is none, must hold the call as
+// JSON. end is the offset just past the match.
+func findInlineToolCall(pattern *regexp.Regexp, text string) (call inlineToolCall, end int, found bool) {
+	for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		payload := text[loc[0]:loc[1]]
+		if len(loc) >= 4 && loc[2] >= 0 {
+			payload = text[loc[2]:loc[3]]
+		}
+		if call, ok := parseInlineToolCall(payload); ok {
+			return call, loc[1], true
+		}
+	}
+	return inlineToolCall{}, 0, false
+}
+
+// parseInlineToolCall decodes a tool call written as JSON.
+func parseInlineToolCall(payload string) (inlineToolCall, bool) {
+	var call inlineToolCall
+	if err := json.Unmarshal([]byte(payload), &call); err != nil || call.Name == "" {
+		return inlineToolCall{}, false
+	}
+	return call, true
+}
+
+// runInlineToolCall executes a tool call found in the chat model's output
+// and returns the history extended with its result. A call to an unknown
+// tool gets an error result, so the model is not asked the same thing
+// again. An error means the turn must be aborted.
+func (app *App) runInlineToolCall(ctx context.Context, history []llm.Message, call inlineToolCall) ([]llm.Message, error) {
+	similarTool, found := findSimilarTool(call.Name, app.ollamaTools)
+	if !found {
+		systemColor.Printf("Warning: Inline tool call to unknown tool '%s'.\n", call.Name)
+		return app.recordToolResult(history, call.Name, fmt.Sprintf("Error: there is no tool named %s", call.Name)), nil
+	}
+	toolColor.Printf("%s Calling inline tool: %s with args: %v\n", iconTool, similarTool, call.Arguments)
+	mcpResult, err := app.callTool(ctx, similarTool, call.Arguments)
+	result := mcpResult.Text
+	if err != nil {
+		if result, err = app.handleToolError(similarTool, err); err != nil {
+			return history, err
+		}
+	}
+	return app.recordToolResult(history, similarTool, result), nil
+}
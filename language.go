@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// languageNames are the supported language codes and the language named in
+// the instruction added to the system prompt.
+var languageNames = map[string]string{
+	"ar": "Arabic",
+	"ca": "Catalan",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"hu": "Hungarian",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"no": "Norwegian",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"th": "Thai",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+func languageCodes() []string {
+	codes := make([]string, 0, len(languageNames))
+	for code := range languageNames {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// languageFromLocale returns the language code of the first set locale,
+// such as "de" for "de_DE.UTF-8", or "" when none names a supported
+// language.
+func languageFromLocale(locales ...string) string {
+	for _, locale := range locales {
+		if locale == "" {
+			continue
+		}
+		code := strings.ToLower(locale)
+		if i := strings.IndexAny(code, "_.@-"); i >= 0 {
+			code = code[:i]
+		}
+		if _, ok := languageNames[code]; ok {
+			return code
+		}
+		return ""
+	}
+	return ""
+}
+
+// languageInstruction asks the model to answer in the configured language.
+func (app *App) languageInstruction() string {
+	name, ok := languageNames[app.config.Language]
+	if !ok {
+		return ""
+	}
+	return "Always respond in " + name + ", whatever the language of the question."
+}
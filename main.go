@@ -3,20 +3,23 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
-	"github.com/joho/godotenv"
-	"github.com/parakeet-nest/parakeet/completion"
-	"github.com/parakeet-nest/parakeet/enums/option"
-	"github.com/parakeet-nest/parakeet/history"
+	"github.com/mattn/go-isatty"
 	"github.com/parakeet-nest/parakeet/llm"
-	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -25,117 +28,112 @@ const (
 	RoleAssistant           = "assistant"
 	MaxConversationMessages = 4
 	defaultSystemPrompt     = ""
+	truncatedMarker         = "\n[truncated]"
+	SystemPromptFirst       = "first"
+	SystemPromptLast        = "last"
+	toolUsedFormat          = "I used %s and got this result:"
+	maxInputLineBytes       = 16 << 20
 )
 
-type MCPServer struct {
-	Name    string   `yaml:"name"`
-	Command string   `yaml:"command"`
-	Args    []string `yaml:"args"`
-}
-
-type MCPConfig struct {
-	Servers []MCPServer `yaml:"servers"`
-}
-
-type Config struct {
-	OllamaURL          string    `yaml:"ollama_url"`
-	ChatModel          string    `yaml:"chat_model"`
-	ToolsModel         string    `yaml:"tools_model"`
-	SystemPrompt       string    `yaml:"system_prompt"`
-	EnableMCP          bool      `yaml:"enable_mcp"`
-	Temperature        float64   `yaml:"temperature"`
-	RepeatLastN        int       `yaml:"repeat_last_n"`
-	RepeatPenalty      float64   `yaml:"repeat_penalty"`
-	ToolsTemperature   float64   `yaml:"tools_temperature"`
-	ToolsRepeatLastN   int       `yaml:"tools_repeat_last_n"`
-	ToolsRepeatPenalty float64   `yaml:"tools_repeat_penalty"`
-	MCP                MCPConfig `yaml:"mcp"`
-}
-
-func loadConfig() Config {
-	var config Config
+var lastMsgID atomic.Int64
 
-	_ = godotenv.Load()
-
-	yamlFile, err := os.ReadFile("config.yml")
-	if err != nil {
-		log.Fatalf("Failed to read config.yaml: %v", err)
+// generateMsgID returns a timestamp based id that is strictly increasing,
+// so ids stay unique and ordered even when generated in quick succession.
+func generateMsgID() string {
+	for {
+		last := lastMsgID.Load()
+		id := max(time.Now().UnixNano(), last+1)
+		if lastMsgID.CompareAndSwap(last, id) {
+			return fmt.Sprintf("%d", id)
+		}
 	}
-
-	err = yaml.Unmarshal(yamlFile, &config)
-	if err != nil {
-		log.Fatalf("Failed to unmarshal YAML: %v", err)
-	}
-
-	config.OllamaURL = getEnv("OLLAMA_HOST", config.OllamaURL)
-	config.ChatModel = getEnv("LLM_CHAT", config.ChatModel)
-	config.ToolsModel = getEnv("LLM_WITH_TOOLS_SUPPORT", config.ToolsModel)
-	config.SystemPrompt = getEnv("SYSTEM_PROMPT", config.SystemPrompt)
-	config.EnableMCP = getEnvBool("ENABLE_MCP", config.EnableMCP)
-	config.Temperature = getEnvFloat("TEMPERATURE", config.Temperature)
-	config.RepeatLastN = getEnvInt("REPEAT_LAST_N", config.RepeatLastN)
-	config.RepeatPenalty = getEnvFloat("REPEAT_PENALTY", config.RepeatPenalty)
-	config.ToolsTemperature = getEnvFloat("TOOLS_TEMPERATURE", config.ToolsTemperature)
-	config.ToolsRepeatLastN = getEnvInt("TOOLS_REPEAT_LAST_N", config.ToolsRepeatLastN)
-	config.ToolsRepeatPenalty = getEnvFloat("TOOLS_REPEAT_PENALTY", config.ToolsRepeatPenalty)
-
-	return config
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+// getLastMessages trims the history to the configured window: the newest
+// history_window messages (or a weighted selection of them), or the newest
+// messages fitting in history_tokens. The messages at the pinned indexes are
+// always kept.
+func getLastMessages(messages []llm.Message, config Config, pinned []int) []llm.Message {
+	var keep []int
+	switch {
+	case config.HistoryTokens > 0:
+		keep = tokenBudgetIndexes(messages, config.HistoryTokens)
+		// A shorter keep is never empty, as the latest message always
+		// fits, so keep[0] is the oldest message within the budget.
+		if config.Retention.Policy != RetentionTail && len(keep) < len(messages) {
+			keep = tailIndexes(messages, alignToExchange(messages, keep[0]))
+		}
+	case config.HistoryWindow < 0 || len(messages) <= config.HistoryWindow:
+		return messages
+	case config.Retention.Policy == RetentionWeighted:
+		keep = weightedIndexes(messages, config.HistoryWindow, config.Retention)
+	case config.Retention.Policy == RetentionTail:
+		keep = tailIndexes(messages, len(messages)-config.HistoryWindow)
+	default:
+		keep = tailIndexes(messages, alignToExchange(messages, len(messages)-config.HistoryWindow))
+	}
+	if len(keep) == len(messages) {
+		return messages
+	}
+	if config.AlwaysKeepLastToolResult {
+		keep = pinLastToolResult(messages, keep)
+	}
+	for _, i := range pinned {
+		if i < len(messages) {
+			keep = addIndex(keep, i)
+		}
 	}
-	return value
-}
 
-func getEnvBool(key string, defaultValue bool) bool {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+	kept := make([]llm.Message, 0, len(keep))
+	for _, i := range keep {
+		kept = append(kept, messages[i])
 	}
-	return strings.ToLower(value) == "true"
+	return kept
 }
 
-func getEnvFloat(key string, defaultValue float64) float64 {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	var result float64
-	_, err := fmt.Sscanf(value, "%f", &result)
-	if err != nil {
-		return defaultValue
+// systemPrompt joins the auto context, the configured system prompt, its
+// layers, the on_start output, the language instruction and any runtime
+// overlays, in that order.
+func (app *App) systemPrompt() string {
+	parts := []string{app.autoContext(), app.config.SystemPrompt}
+	parts = append(parts, app.config.SystemPromptLayers...)
+	parts = append(parts, app.startupContext, app.languageInstruction(), app.responseFormat.instruction())
+	parts = append(parts, app.overlays...)
+	var nonEmpty []string
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
 	}
-	return result
+	return strings.Join(nonEmpty, "\n\n")
 }
 
-func getEnvInt(key string, defaultValue int) int {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func (app *App) buildMessages(history []llm.Message) []llm.Message {
+	config := app.config
+	systemMessage := llm.Message{Role: RoleSystem, Content: app.systemPrompt()}
+	messages := make([]llm.Message, 0, len(history)+len(config.FewShot)+1)
+	if config.SystemPromptPosition != SystemPromptLast {
+		messages = append(messages, systemMessage)
 	}
-	var result int
-	_, err := fmt.Sscanf(value, "%d", &result)
-	if err != nil {
-		return defaultValue
+	for _, example := range config.FewShot {
+		messages = append(messages, llm.Message{Role: example.Role, Content: example.Content})
 	}
-	return result
-}
-
-func generateMsgID() string {
-	return fmt.Sprintf("%d", time.Now().UnixNano())
+	messages = append(messages, history...)
+	if config.SystemPromptPosition == SystemPromptLast {
+		messages = append(messages, systemMessage)
+	}
+	return config.RoleMap.outgoing(normalizeRoles(messages, config.RoleAlternation))
 }
 
-func getLastMessages(messages []llm.Message) []llm.Message {
-	if MaxConversationMessages < 0 {
-		return messages
+func truncateToolResult(text string, maxBytes int) string {
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
 	}
-	if len(messages) <= MaxConversationMessages {
-		return messages
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
 	}
-	return messages[len(messages)-MaxConversationMessages:]
+	return text[:cut] + truncatedMarker
 }
 
 func toolExists(toolName string, tools []llm.Tool) bool {
@@ -163,77 +161,266 @@ func findSimilarTool(toolName string, tools []llm.Tool) (string, bool) {
 	return "", false
 }
 
-func main() {
-	config := loadConfig()
-	userColor := color.New(color.FgCyan, color.Bold)
-	assistantColor := color.New(color.FgGreen, color.Bold)
-	systemColor := color.New(color.FgYellow)
-	toolColor := color.New(color.FgMagenta)
+var (
+	userColor      = color.New(color.FgCyan, color.Bold)
+	assistantColor = color.New(color.FgGreen, color.Bold)
+	systemColor    = color.New(color.FgYellow)
+	toolColor      = color.New(color.FgMagenta)
+	chunkColor     = color.New(color.Faint)
+)
+
+// stdinScanner reads both the REPL input and the answers to prompts, so
+// that neither reads ahead of the other.
+var stdinScanner = newInputScanner(os.Stdin)
+
+// resetStdinScanner replaces stdinScanner after the input ended: the
+// terminal keeps working after a Ctrl-D, but a scanner does not.
+func resetStdinScanner() {
+	stdinScanner = newInputScanner(os.Stdin)
+}
 
-	conversation := history.MemoryMessages{
-		Messages: make(map[string]llm.MessageRecord),
+// readAnswer reads the answer to a prompt, up to and including the newline.
+// The TUI replaces it while it runs, since it owns stdin then.
+var readAnswer = func() (string, error) {
+	if !stdinScanner.Scan() {
+		if err := stdinScanner.Err(); err != nil {
+			return "", err
+		}
+		resetStdinScanner()
+		return "", io.EOF
 	}
+	return stdinScanner.Text() + "\n", nil
+}
 
-	_, err := conversation.SaveMessage(generateMsgID(), llm.Message{
-		Role:    RoleSystem,
-		Content: config.SystemPrompt,
-	})
-	if err != nil {
-		log.Fatalf("Failed to save system message: %v", err)
+// confirm asks a yes or no question; anything but yes is a no.
+func confirm(prompt string) bool {
+	return confirmOr(prompt, false)
+}
+
+// confirmOr is confirm with onEOF as the answer when the input ends, e.g.
+// with Ctrl-D, before anything was typed.
+func confirmOr(prompt string, onEOF bool) bool {
+	systemColor.Printf("%s [y/N] ", prompt)
+	answer, err := readAnswer()
+	if err == io.EOF && answer == "" {
+		fmt.Fprintln(color.Output)
+		return onEOF
 	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
 
-	var ollamaTools []llm.Tool
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// choose lists options and reads the number of the one picked. It reports
+// false when the answer is not a valid choice.
+func choose(prompt string, options []string) (int, bool) {
+	systemColor.Println(prompt)
+	for i, option := range options {
+		systemColor.Printf("  %d. %s\n", i+1, option)
+	}
+	systemColor.Printf("Choice [1-%d]: ", len(options))
+	answer, _ := readAnswer()
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(options) {
+		return 0, false
+	}
+	return choice - 1, true
+}
 
-	var mcpClient mcpstdio.Client
+// App holds the state of one chat session.
+type App struct {
+	ctx          context.Context
+	out          io.Writer
+	config       Config
+	conversation HistoryStore
+	sessionID    string
+	// sessionTitleText is generated once, on the first save.
+	sessionTitleText string
+	mcp              *mcpPool
+	mcpActive        bool
+	ollamaTools      []llm.Tool
+	// staticTools maps the tools loaded from tools_file to their stub result.
+	staticTools map[string]string
+
+	overlays []string
+	// startupContext is the on_start output, kept in the system prompt so
+	// that the history window never drops it.
+	startupContext string
+	branches       map[string][]llm.Message
+	// tags holds the tags of each message, keyed by record id.
+	tags map[string][]string
+	// pins holds the record ids of the pinned messages.
+	pins map[string]bool
+	vars map[string]string
+
+	lastUserMsgID  string
+	lastUserInput  string
+	lastToolName   string
+	lastToolResult string
+	lastAnswer     llm.Answer
+	lastQuery      llm.Query
+	lastResponse   llm.Answer
+	activeModel    string
+	canContinue    bool
+	turn           int
+
+	tee *os.File
+	// thinkingFile receives the <think> sections of reasoning models,
+	// which are then left out of the answer unless showThinking is set.
+	thinkingFile *os.File
+	// progressFile receives a JSON line per agent step; see writeProgress.
+	progressFile *os.File
+	agentStep    int
+	showThinking bool
+	turnThinking strings.Builder
+	outDir       string
+	extractDir   string
+	pager        bool
+	showChunks   bool
+	// typeDelay paces the display of answers; see typeWriter.
+	typeDelay    time.Duration
+	verbose      bool
+	outputFormat string
+	safeMode     bool
+	pickServer   bool
+
+	// turnToolCalls collects the tools called during the current turn for
+	// structured output.
+	turnToolCalls []turnToolCall
+	// turnSources collects the sources cited by tool results this turn.
+	turnSources []string
+	// usage adds up the tokens and cost of the current turn.
+	usage        turnUsage
+	sessionCost  float64
+	budgetWarned bool
+	showStats    bool
+	// toolsPaused skips the tools query for the rest of the session, or
+	// until /tools on.
+	toolsPaused bool
+	// variants holds the answers generated by /vary until one is picked.
+	variants []string
+	// chatOptionOverrides and toolsOptionOverrides hold the options set
+	// with /opts, keyed by option name.
+	chatOptionOverrides  map[string]any
+	toolsOptionOverrides map[string]any
+	// responseFormat is the answer format set with /format.
+	responseFormat responseFormat
+	// turnMu is held while a turn runs; see errTurnBusy.
+	turnMu sync.Mutex
+	// sessionMu is held while a command or turn runs; see withSession.
+	sessionMu sync.Mutex
+}
 
-	if config.EnableMCP && len(config.MCP.Servers) > 0 {
-		systemColor.Println("Initializing MCP client...")
+func (app *App) initMCP() error {
+	app.ollamaTools = nil
+	app.initStaticTools()
 
-		server := config.MCP.Servers[0]
-		systemColor.Printf("Using MCP server: %s\n", server.Name)
+	if !app.config.EnableMCP {
+		return nil
+	}
+	if len(app.config.MCP.Servers) == 0 {
+		systemColor.Println("MCP enabled but no servers specified in config. Continuing without MCP tools support.")
+		return nil
+	}
 
-		mcpClient, err = mcpstdio.NewClient(ctx, server.Command, []string{}, server.Args...)
+	systemColor.Println("Initializing MCP servers...")
+	app.mcp = newMCPPool(app.ctx, app.config.MCP)
+	app.mcp.pickServer = app.pickServer
+	app.ollamaTools = append(app.ollamaTools, app.mcp.discover()...)
+	app.mcpActive = true
+	return nil
+}
 
-		if err != nil {
-			systemColor.Printf("Warning: Failed to initialize MCP client: %v\n", err)
-			systemColor.Println("Continuing without MCP tools support.")
-		} else {
-			_, err = mcpClient.Initialize()
-			if err != nil {
-				log.Fatalln("Failed to initialize MCP client", err)
-			}
+// resetConversation clears the history and seeds it with the system prompt.
+func (app *App) resetConversation() error {
+	if err := app.conversation.Clear(); err != nil {
+		return err
+	}
+	app.turn = 0
+	app.lastUserMsgID = ""
+	app.canContinue = false
+	app.tags = nil
+	app.pins = nil
+	app.variants = nil
+	return app.conversation.Save(generateMsgID(), llm.Message{
+		Role:    RoleSystem,
+		Content: app.config.SystemPrompt,
+	})
+}
 
-			tools, err := mcpClient.ListTools()
-			if err != nil {
-				systemColor.Printf("Warning: Failed to get MCP tools: %v\n", err)
-			} else {
-				ollamaTools = tools
-				toolColor.Printf("[%s] tools loaded successfully:\n", server.Name)
-				for i, tool := range ollamaTools {
-					toolColor.Printf("  %d. %s\n", i+1, tool.Function.Name)
-				}
-			}
-		}
-	} else if config.EnableMCP {
-		systemColor.Println("MCP enabled but no servers specified in config. Continuing without MCP tools support.")
+// startNewSession moves on to a new session ID with its own history
+// store, seeded like a fresh start. The messages of the previous session
+// stay where they are.
+func (app *App) startNewSession() error {
+	id := newSessionID()
+	if id == app.sessionID {
+		id += "-1"
+	}
+	conversation, err := openHistoryStore(app.config, id)
+	if err != nil {
+		return err
+	}
+	app.conversation.Close()
+	app.conversation = conversation
+	app.sessionID = id
+	app.sessionTitleText = ""
+	return app.resetConversation()
+}
+
+func (app *App) closeMCP() {
+	if !app.mcpActive {
+		return
 	}
+	app.mcp.close()
+	app.mcpActive = false
+	app.ollamaTools = nil
+}
+
+// teePrintf writes to the tee file, if any. Writes go straight to the file
+// without buffering so a crash still leaves a usable partial transcript.
+func (app *App) teePrintf(format string, args ...any) {
+	if app.tee == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(app.tee, format, args...); err != nil {
+		systemColor.Printf("Warning: Failed to write tee file, disabling it: %v\n", err)
+		app.tee.Close()
+		app.tee = nil
+	}
+}
 
-	systemColor.Printf("Using model: %s\n", config.ChatModel)
+func (app *App) runREPL() {
+	systemColor.Printf("Using model: %s\n", app.config.ChatModel)
 	systemColor.Println("Type your message and press Enter to chat.")
 	systemColor.Println("Type 'exit' or 'quit' to end the conversation.")
+	systemColor.Println("Type '/help' to list commands.")
 	systemColor.Println("-----------------------------------------------")
-	systemColor.Println("🤖 LLoms chat")
+	systemColor.Println(iconBot + " LLoms chat")
 	systemColor.Println("-----------------------------------------------")
 
-	scanner := bufio.NewScanner(os.Stdin)
+	interactive := isatty.IsTerminal(os.Stdin.Fd())
 	for {
 		userColor.Print("You: ")
-		if !scanner.Scan() {
-			break
+		if !stdinScanner.Scan() {
+			if err := stdinScanner.Err(); err != nil {
+				systemColor.Printf("Failed to read input: %v\n", err)
+				break
+			}
+			if !interactive {
+				// A piped-in batch has run out of lines: that is the
+				// normal way for it to finish.
+				systemColor.Println("\nEnd of input.")
+				break
+			}
+			// Ctrl-D at the prompt is easy to hit by accident, so ask
+			// before ending the session. A second Ctrl-D counts as a yes.
+			fmt.Println()
+			resetStdinScanner()
+			if confirmOr("Quit?", true) {
+				break
+			}
+			continue
 		}
-		userInput := scanner.Text()
+		userInput := stdinScanner.Text()
 		if userInput == "exit" || userInput == "quit" {
 			break
 		}
@@ -242,136 +429,299 @@ func main() {
 			continue
 		}
 
-		_, err := conversation.SaveMessage(generateMsgID(), llm.Message{
-			Role:    RoleUser,
-			Content: userInput,
-		})
+		if strings.HasPrefix(userInput, "/") {
+			app.withSession(func() { app.handleCommand(userInput) })
+			continue
+		}
+
+		var err error
+		app.withSession(func() { err = app.processTurn(userInput) })
 		if err != nil {
-			log.Fatalf("Failed to save user message: %v", err)
+			if errors.Is(err, errTurnAborted) || errors.Is(err, errTurnBusy) {
+				systemColor.Printf("%v\n", err)
+				continue
+			}
+			log.Fatalf("%v", err)
 		}
+	}
+}
 
-		allMessages, err := conversation.GetAllMessages()
+func newInputScanner(input io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(input)
+	// Pasted text can be a single very long line; the default 64KiB limit
+	// would end the session with "token too long".
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineBytes)
+	return scanner
+}
+
+// subcommands are the words main accepts after the flags.
+var subcommands = []string{"view", "attach", "sessions", "replay", "detached", "import"}
+
+func main() {
+	forceMCP := flag.Bool("mcp", false, "Enable MCP tools for this run, whatever the config says")
+	noMCP := flag.Bool("no-mcp", false, "Disable MCP tools for this run, whatever the config says")
+	tuiMode := flag.Bool("tui", false, "Use the terminal UI instead of the plain REPL")
+	var extractCode optionalDirFlag
+	flag.Var(&extractCode, "extract-code", "Save code blocks with a suggested filename to this directory (default: the current one)")
+	outDir := flag.String("out-dir", "", "Also write each answer to its own Markdown file in this directory")
+	teeFile := flag.String("tee", "", "Also append the conversation transcript to this file")
+	progressFile := flag.String("progress-file", "", "Append a JSON line per agent step to this file, for monitoring long runs")
+	thinkingFile := flag.String("thinking-file", "", "Append the thinking of reasoning models to this file and leave it out of the answers")
+	showThinking := flag.Bool("show-thinking", false, "Show the thinking of reasoning models, dimmed, apart from the answer")
+	autoPull := flag.Bool("auto-pull", false, "Pull missing models without asking")
+	safeMode := flag.Bool("safe", false, "Block tools matching safe_mode_tool_patterns")
+	pickServer := flag.Bool("pick-server", false, "Ask which MCP server to use when several provide the same tool")
+	showStats := flag.Bool("stats", false, "Show the token counts and estimated cost of each turn")
+	showChunks := flag.Bool("show-chunks", false, "Debug: mark the boundary of every streamed chunk")
+	typeDelay := flag.Int("type-delay", 0, "Show answers one character every this many milliseconds, for a steady typing effect")
+	verbose := flag.Bool("verbose", false, "Report housekeeping such as messages evicted by message_ttl")
+	usePager := flag.Bool("pager", false, "Show each full response through $PAGER (default less)")
+	metricsAddr := flag.String("metrics", "", "Serve Prometheus metrics on this address, e.g. :9090")
+	printConfig := flag.Bool("print-config", false, "Print the resolved config and exit")
+	outputFormat := flag.String("output-format", OutputText, "Write each answer to stdout as text, json or yaml; with json or yaml the stream goes to stderr")
+	flag.Parse()
+
+	if args := flag.Args(); extractCode.bare && len(args) > 0 && !slices.Contains(subcommands, args[0]) {
+		log.Fatalf("Unexpected argument %q: give the directory as --extract-code=%s", args[0], args[0])
+	}
+	if *forceMCP && *noMCP {
+		log.Fatalf("--mcp and --no-mcp cannot be used together")
+	}
+	if *forceMCP || *noMCP {
+		flagOverrides["enable_mcp"] = *forceMCP
+	}
+
+	// view only reads a saved session, so it needs neither config.yml nor
+	// a reachable model.
+	if args := flag.Args(); len(args) > 0 && args[0] == "view" {
+		if err := viewSession(args[1:]); err != nil {
+			log.Fatalf("Failed to view session: %v", err)
+		}
+		return
+	}
+
+	if !validOutputFormat(*outputFormat) {
+		log.Fatalf("Invalid --output-format %q: expected %s, %s or %s", *outputFormat, OutputText, OutputJSON, OutputYAML)
+	}
+	if *tuiMode && *outputFormat != OutputText {
+		log.Fatalf("--output-format %s cannot be used with --tui", *outputFormat)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	app := &App{
+		ctx:          ctx,
+		out:          os.Stdout,
+		config:       loadConfig(),
+		pager:        *usePager,
+		showChunks:   *showChunks,
+		typeDelay:    time.Duration(*typeDelay) * time.Millisecond,
+		verbose:      *verbose,
+		showThinking: *showThinking,
+		showStats:    *showStats,
+		outDir:       *outDir,
+		extractDir:   extractCode.dir,
+		outputFormat: *outputFormat,
+		safeMode:     *safeMode,
+		pickServer:   *pickServer,
+	}
+	setASCIIIcons(app.config.ASCIIIcons)
+	if app.outputFormat != OutputText {
+		// Keep stdout for the structured records only.
+		app.out = os.Stderr
+		color.Output = os.Stderr
+	}
+
+	if *printConfig {
+		formatted, err := formatConfig(app.config)
 		if err != nil {
-			log.Fatalf("Failed to get conversation history: %v", err)
+			log.Fatalf("Failed to format config: %v", err)
 		}
+		fmt.Print(formatted)
+		return
+	}
 
-		messages := []llm.Message{
-			{Role: RoleSystem, Content: config.SystemPrompt},
+	if args := flag.Args(); len(args) > 0 && args[0] == "attach" {
+		if len(args) != 2 {
+			log.Fatalf("Usage: %s attach <session>", os.Args[0])
+		}
+		if err := attach(app.config, args[1]); err != nil {
+			log.Fatalf("Failed to attach: %v", err)
 		}
+		return
+	}
 
-		messages = append(messages, getLastMessages(allMessages)...)
-
-		chatOptions := llm.SetOptions(map[string]any{
-			option.Temperature:   config.Temperature,
-			option.RepeatLastN:   config.RepeatLastN,
-			option.RepeatPenalty: config.RepeatPenalty,
-			option.NumCtx:        25920,
-			option.Mirostat:      1,
-			option.MirostatTau:   5.0,
-			option.MirostatEta:   0.1,
-		})
-
-		if len(ollamaTools) > 0 {
-			toolsOptions := llm.SetOptions(map[string]any{
-				option.Temperature:   config.ToolsTemperature,
-				option.RepeatLastN:   config.ToolsRepeatLastN,
-				option.RepeatPenalty: config.ToolsRepeatPenalty,
-				option.NumCtx:        25920,
-				option.Mirostat:      1,
-				option.MirostatTau:   1.0,
-				option.MirostatEta:   0.1,
-				option.TopK:          40,
-				option.TopP:          0.9,
-			})
-
-			toolsQuery := llm.Query{
-				Model:    config.ToolsModel,
-				Messages: messages,
-				Tools:    ollamaTools,
-				Options:  toolsOptions,
-				Format:   "json",
-			}
+	if args := flag.Args(); len(args) > 0 && args[0] == "sessions" {
+		if err := listSessions(app.config); err != nil {
+			log.Fatalf("Failed to list sessions: %v", err)
+		}
+		return
+	}
+
+	var err error
+	app.sessionID = newSessionID()
+	app.conversation, err = openHistoryStore(app.config, app.sessionID)
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	// /reset and /detach replace the store, so close whichever is current.
+	defer func() { app.conversation.Close() }()
+
+	err = app.resetConversation()
+	if err != nil {
+		log.Fatalf("Failed to save system message: %v", err)
+	}
+
+	if err := configureHTTPTransport(app.config); err != nil {
+		log.Fatalf("Failed to configure HTTP client: %v", err)
+	}
 
-			answer, err := completion.Chat(config.OllamaURL, toolsQuery)
+	if err := checkAPI(app.config.apiURL()); err != nil {
+		systemColor.Printf("Warning: Ollama API not reachable at %s: %v\n", app.config.apiURL(), err)
+	}
+
+	models := []string{app.config.ChatModel}
+	if (app.config.EnableMCP || app.config.ToolsFile != "") && app.config.ToolsModel != app.config.ChatModel {
+		models = append(models, app.config.ToolsModel)
+	}
+	for _, model := range models {
+		if err := ensureModel(app.config.apiURL(), model, *autoPull); err != nil {
+			systemColor.Printf("Warning: Could not check model %s: %v\n", model, err)
+		}
+	}
+
+	shutdownTracing, err := initTracing(ctx)
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
+	if *metricsAddr != "" {
+		serveMetrics(*metricsAddr)
+		systemColor.Printf("Serving metrics on %s/metrics\n", *metricsAddr)
+	}
+
+	if *teeFile != "" {
+		app.tee, err = os.OpenFile(*teeFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open tee file: %v", err)
+		}
+		defer app.tee.Close()
+	}
+	if *progressFile != "" {
+		app.progressFile, err = os.OpenFile(*progressFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open progress file: %v", err)
+		}
+		defer app.progressFile.Close()
+	}
+	if *thinkingFile != "" {
+		app.thinkingFile, err = os.OpenFile(*thinkingFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open thinking file: %v", err)
+		}
+		defer app.thinkingFile.Close()
+	}
+
+	args := flag.Args()
+	// A detached run continues a saved session in the background: it has
+	// no use for a warm model or on_start output, and saves the session
+	// itself when the turn is over.
+	if len(args) == 0 || args[0] != "detached" {
+		app.startWarmUp()
+		app.runOnStart()
+	}
+
+	var replayArgs *replayOptions
+	var detachedPrompt string
+	if len(args) > 0 {
+		switch args[0] {
+		case "replay":
+			options, err := parseReplayArgs(args[1:], app.config.ChatModel)
+			if err != nil {
+				log.Fatalf("Usage: %s replay <session.json> [--model <model>] [--out <report.json>]: %v", os.Args[0], err)
+			}
+			options.model = app.config.resolveModel(options.model)
+			if err := ensureModel(app.config.apiURL(), options.model, *autoPull); err != nil {
+				systemColor.Printf("Warning: Could not check model %s: %v\n", options.model, err)
+			}
+			replayArgs = &options
+		case "detached":
+			if len(args) != 3 {
+				log.Fatalf("Usage: %s detached <session.json> <prompt>", os.Args[0])
+			}
+			session, err := readSessionFile(args[1])
+			if err != nil {
+				log.Fatalf("Failed to read session: %v", err)
+			}
+			if err := app.loadSession(session); err != nil {
+				log.Fatalf("Failed to load session: %v", err)
+			}
+			detachedPrompt = args[2]
+		case "import":
+			if len(args) != 2 {
+				log.Fatalf("Usage: %s import <file.json>", os.Args[0])
+			}
+			imported, err := app.importTranscript(args[1])
 			if err != nil {
-				systemColor.Printf("Tools check failed: %v\n", err)
-				systemColor.Println("Continuing with standard chat...")
-			} else if len(answer.Message.ToolCalls) > 0 {
-				toolCall := answer.Message.ToolCalls[0]
-
-				if similarTool, found := findSimilarTool(toolCall.Function.Name, ollamaTools); !found {
-					systemColor.Printf("Warning: Tool '%s' does not exist and no similar tools found. Continuing with standard chat...\n",
-						toolCall.Function.Name)
-				} else {
-					if similarTool != toolCall.Function.Name {
-						toolColor.Printf("🛠️ Using similar tool: '%s' instead of '%s'\n",
-							similarTool, toolCall.Function.Name)
-					}
-					toolColor.Printf("🛠️ Calling tool: %s with args: %s\n",
-						similarTool, toolCall.Function.Arguments)
-					mcpResult, err := mcpClient.CallTool(similarTool, toolCall.Function.Arguments)
-
-					if err != nil {
-						systemColor.Printf("Tool call failed: %v\n", err)
-					} else {
-						contentFromTool := mcpResult.Text
-						toolColor.Printf("🛠️ Tool result: %v\n",
-							mcpResult)
-						messages = append(messages,
-							llm.Message{Role: RoleAssistant, Content: fmt.Sprintf("I used %s and got this result:", toolCall.Function.Name)},
-							llm.Message{Role: RoleUser, Content: contentFromTool},
-						)
-
-						_, err = conversation.SaveMessage(generateMsgID(), llm.Message{
-							Role:    RoleAssistant,
-							Content: fmt.Sprintf("I used %s and got this result:", toolCall.Function.Name),
-						})
-						if err != nil {
-							systemColor.Printf("Tool call failed: %v\n", err)
-						}
-
-						_, err = conversation.SaveMessage(generateMsgID(), llm.Message{
-							Role:    RoleUser,
-							Content: contentFromTool,
-						})
-						if err != nil {
-							systemColor.Printf("Tool result failed: %v\n", err)
-						}
-					}
-				}
+				log.Fatalf("Failed to import transcript: %v", err)
 			}
+			systemColor.Printf("Imported %d messages from %s\n", imported, args[1])
+		default:
+			log.Fatalf("Unknown subcommand: %s", args[0])
 		}
+	}
 
-		query := llm.Query{
-			Model:    config.ChatModel,
-			Messages: messages,
-			Options:  chatOptions,
+	if err := app.initMCP(); err != nil {
+		log.Fatalln("Failed to initialize MCP client", err)
+	}
+
+	if replayArgs != nil {
+		var err error
+		app.withSession(func() { err = app.replay(*replayArgs) })
+		app.closeMCP()
+		shutdownTracing(context.Background())
+		if err != nil {
+			log.Fatalf("Replay failed: %v", err)
 		}
+		return
+	}
 
-		assistantColor.Print("LLoms: ")
-		var assistantResponse strings.Builder
-		_, err = completion.ChatStream(config.OllamaURL, query,
-			func(answer llm.Answer) error {
-				fmt.Print(answer.Message.Content)
-				assistantResponse.WriteString(answer.Message.Content)
-				return nil
-			},
-		)
+	if detachedPrompt != "" {
+		err := app.runDetached(detachedPrompt)
+		app.closeMCP()
+		shutdownTracing(context.Background())
 		if err != nil {
-			log.Fatalf("Failed to get response from LLM: %v", err)
+			systemColor.Printf("Detached run failed: %v\n", err)
 		}
-		fmt.Println()
+		// Tells attach that the log is complete.
+		fmt.Println(detachedDoneMarker)
+		return
+	}
 
-		_, err = conversation.SaveMessage(generateMsgID(), llm.Message{
-			Role:    RoleAssistant,
-			Content: assistantResponse.String(),
-		})
+	// Only an interactive session runs long enough to need saving along
+	// the way.
+	app.startAutoSave()
+	if *tuiMode {
+		if err := runTUI(app); err != nil {
+			log.Fatalf("TUI failed: %v", err)
+		}
+	} else {
+		app.runREPL()
+	}
 
+	if app.config.SaveSessions {
+		var err error
+		app.withSession(func() { err = app.saveSession() })
 		if err != nil {
-			log.Fatalf("Failed to save assistant response: %v", err)
+			systemColor.Printf("Warning: Failed to save session: %v\n", err)
 		}
 	}
 
+	app.closeMCP()
+	if err := shutdownTracing(context.Background()); err != nil {
+		systemColor.Printf("Warning: Failed to flush traces: %v\n", err)
+	}
 	systemColor.Println("Goodbye!")
 	os.Exit(0)
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInputScannerLongLine(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	scanner := newInputScanner(strings.NewReader(long + "\nnext\n"))
+	if !scanner.Scan() {
+		t.Fatalf("Scan failed on a %d byte line: %v", len(long), scanner.Err())
+	}
+	if scanner.Text() != long {
+		t.Errorf("got a %d byte line, want %d bytes", len(scanner.Text()), len(long))
+	}
+	if !scanner.Scan() || scanner.Text() != "next" {
+		t.Errorf("the line after the long one was not read: %q, %v", scanner.Text(), scanner.Err())
+	}
+}
+
+func TestInputScannerTooLong(t *testing.T) {
+	scanner := newInputScanner(strings.NewReader(strings.Repeat("x", maxInputLineBytes+1) + "\n"))
+	if scanner.Scan() {
+		t.Fatalf("Scan accepted a line longer than %d bytes", maxInputLineBytes)
+	}
+	if err := scanner.Err(); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("got error %v, want %v", err, bufio.ErrTooLong)
+	}
+}
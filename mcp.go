@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/llm"
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+)
+
+// mcpServer tracks one configured MCP server. Its tools are discovered once
+// up front; the process itself may be stopped and started again on demand.
+type mcpServer struct {
+	config    MCPServer
+	client    mcpstdio.Client
+	running   bool
+	startedAt time.Time
+	lastUsed  time.Time
+	// pid is the server's process, or 0 when it is not known.
+	pid int
+	// abandoned is set when the server stopped answering and its process
+	// could not be killed. It is not started again, so that at most one
+	// stray process is left behind.
+	abandoned bool
+	cancel    context.CancelFunc
+}
+
+var (
+	errMCPTimeout   = errors.New("timed out")
+	errMCPTransport = errors.New("lost the connection to the MCP server")
+)
+
+// mcpTransportErrors are the messages of the mcp-go errors that mean a
+// request never reached the server, as opposed to the server answering
+// with an error.
+var mcpTransportErrors = []string{"failed to write request", "client not initialized"}
+
+// lostConnection reports whether err is an mcp-go error for a request that
+// never reached the server.
+func lostConnection(err error) bool {
+	return err != nil && slices.ContainsFunc(mcpTransportErrors, func(message string) bool {
+		return strings.Contains(err.Error(), message)
+	})
+}
+
+// retryable reports whether a failed tool call may succeed when tried
+// again: it timed out or never reached the server.
+func retryable(err error) bool {
+	return errors.Is(err, errMCPTimeout) || errors.Is(err, errMCPTransport)
+}
+
+// withTimeout runs fn, giving up with errMCPTimeout after timeout. fn keeps
+// running in the background; it is up to the caller to unblock it.
+func withTimeout(timeout time.Duration, fn func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("%w after %s", errMCPTimeout, timeout)
+	}
+}
+
+// start launches the server and runs the MCP handshake. A server that
+// does not answer within timeout is killed.
+func (s *mcpServer) start(ctx context.Context, timeout time.Duration) error {
+	if s.abandoned {
+		return fmt.Errorf("%w: its last process could not be killed, restart lloms to use it again", errMCPTransport)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	var client mcpstdio.Client
+	var pid atomic.Int64
+	err := withTimeout(timeout, func() error {
+		var err error
+		client, err = mcpstdio.NewClient(ctx, s.config.Command, []string{}, s.config.Args...)
+		if err != nil {
+			return err
+		}
+		pid.Store(int64(serverPID(&client)))
+		if _, err := client.Initialize(); err != nil {
+			client.Close()
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errMCPTimeout) && pid.Load() != 0 {
+			killProcess(int(pid.Load()))
+		}
+		cancel()
+		return err
+	}
+
+	s.client = client
+	s.cancel = cancel
+	s.pid = int(pid.Load())
+	s.running = true
+	s.startedAt = time.Now()
+	s.lastUsed = s.startedAt
+	return nil
+}
+
+func (s *mcpServer) stop() {
+	if !s.running {
+		return
+	}
+	if err := s.client.Close(); err != nil {
+		systemColor.Printf("Warning: Failed to close MCP server %s: %v\n", s.config.Name, err)
+	}
+	s.cancel()
+	s.running = false
+}
+
+// kill stops a server that stopped answering. Without a known pid the
+// process is abandoned, since closing it could block forever.
+func (s *mcpServer) kill() {
+	if s.pid == 0 {
+		s.cancel()
+		s.running = false
+		s.abandoned = true
+		return
+	}
+	killProcess(s.pid)
+	s.client.Close()
+	s.cancel()
+	s.running = false
+}
+
+// serverPID returns the pid of the process client talks to. mcpstdio starts
+// the process without exposing it, so the pid is read from the client's
+// fields; it is 0 if they are not laid out as expected. TestServerPID
+// fails when a dependency update moves them.
+func serverPID(client *mcpstdio.Client) int {
+	value := reflect.ValueOf(client).Elem()
+	for _, field := range []string{"mcpClient", "cmd", "Process"} {
+		value = value.FieldByName(field)
+		if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+			return 0
+		}
+		value = value.Elem()
+	}
+	if pid := value.FieldByName("Pid"); pid.Kind() == reflect.Int {
+		return int(pid.Int())
+	}
+	return 0
+}
+
+func killProcess(pid int) {
+	if process, err := os.FindProcess(pid); err == nil {
+		process.Kill()
+	}
+}
+
+// mcpPool routes tool calls to the server providing each tool. Lazy servers
+// are only kept running once one of their tools has been called, and at
+// most maxActive servers run at a time.
+type mcpPool struct {
+	ctx     context.Context
+	servers []*mcpServer
+	// toolOwners lists the servers providing each tool, in config order.
+	toolOwners map[string][]*mcpServer
+	lazy       bool
+	maxActive  int
+	// initTimeout bounds starting a server and listing its tools.
+	initTimeout time.Duration
+	// pickServer asks the user which server to use when several provide a
+	// tool; the choice is remembered in picked.
+	pickServer bool
+	picked     map[string]*mcpServer
+	// mu serializes calls from sessions sharing the pool.
+	mu sync.Mutex
+}
+
+func newMCPPool(ctx context.Context, config MCPConfig) *mcpPool {
+	pool := &mcpPool{
+		ctx:        ctx,
+		toolOwners: map[string][]*mcpServer{},
+		lazy:       config.Lazy,
+		maxActive:  config.MaxActiveServers,
+		picked:     map[string]*mcpServer{},
+	}
+	pool.initTimeout, _ = time.ParseDuration(config.InitTimeout)
+	for _, server := range config.Servers {
+		pool.servers = append(pool.servers, &mcpServer{config: server})
+	}
+	return pool
+}
+
+// discover starts every server to cache its tool list. Lazy servers are
+// stopped again right away.
+func (p *mcpPool) discover() []llm.Tool {
+	var tools []llm.Tool
+	for _, server := range p.servers {
+		name := server.config.Name
+		if err := server.start(p.ctx, p.initTimeout); err != nil {
+			systemColor.Printf("Warning: Failed to start MCP server %s: %v\n", name, err)
+			continue
+		}
+		var serverTools []llm.Tool
+		err := withTimeout(p.initTimeout, func() error {
+			var err error
+			serverTools, err = server.client.ListTools()
+			return err
+		})
+		if err != nil {
+			systemColor.Printf("Warning: Failed to get tools from MCP server %s: %v\n", name, err)
+			if errors.Is(err, errMCPTimeout) {
+				server.kill()
+			} else {
+				server.stop()
+			}
+			continue
+		}
+
+		toolColor.Printf("[%s] tools loaded successfully:\n", name)
+		for _, tool := range serverTools {
+			owners := p.toolOwners[tool.Function.Name]
+			p.toolOwners[tool.Function.Name] = append(owners, server)
+			if len(owners) > 0 {
+				systemColor.Printf("Note: Tool %s from %s is also provided by %s.\n",
+					tool.Function.Name, name, owners[0].config.Name)
+				continue
+			}
+			tools = append(tools, tool)
+			toolColor.Printf("  %d. %s\n", len(tools), tool.Function.Name)
+		}
+
+		if p.lazy {
+			server.stop()
+		} else {
+			p.enforceLimit(server)
+		}
+	}
+	return tools
+}
+
+// callTool calls a tool on the server that provides it, starting the
+// server first if it is not running. A server that does not answer within
+// timeout (0 for no limit) is killed and started again on the next call.
+func (p *mcpPool) callTool(name string, arguments map[string]any, timeout time.Duration) (mcpstdio.CallToolResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	server, ok := p.ownerOf(name)
+	if !ok {
+		return mcpstdio.CallToolResult{}, fmt.Errorf("no MCP server provides tool %s", name)
+	}
+	if !server.running {
+		systemColor.Printf("Starting MCP server %s...\n", server.config.Name)
+		if err := server.start(p.ctx, p.initTimeout); err != nil {
+			if !errors.Is(err, errMCPTransport) {
+				err = fmt.Errorf("%w: %w", errMCPTransport, err)
+			}
+			return mcpstdio.CallToolResult{}, fmt.Errorf("failed to start MCP server %s: %w", server.config.Name, err)
+		}
+	}
+	server.lastUsed = time.Now()
+	p.enforceLimit(server)
+	var result mcpstdio.CallToolResult
+	call := func() error {
+		var err error
+		result, err = server.client.CallTool(name, arguments)
+		return err
+	}
+	var err error
+	if timeout <= 0 {
+		err = call()
+	} else {
+		err = withTimeout(timeout, call)
+	}
+	switch {
+	case errors.Is(err, errMCPTimeout):
+		server.kill()
+		return mcpstdio.CallToolResult{}, err
+	case lostConnection(err):
+		// The server is gone: stop it so the next call starts it again.
+		server.kill()
+		return mcpstdio.CallToolResult{}, fmt.Errorf("%w: %v", errMCPTransport, err)
+	}
+	return result, err
+}
+
+// ownerOf returns the server to call a tool on: the first one providing it
+// or, in pick-server mode, the one the user chooses.
+func (p *mcpPool) ownerOf(name string) (*mcpServer, bool) {
+	owners := p.toolOwners[name]
+	if len(owners) == 0 {
+		return nil, false
+	}
+	if len(owners) == 1 || !p.pickServer {
+		return owners[0], true
+	}
+	if server, ok := p.picked[name]; ok {
+		return server, true
+	}
+
+	names := make([]string, len(owners))
+	for i, server := range owners {
+		names[i] = server.config.Name
+	}
+	choice, ok := choose(fmt.Sprintf("Tool %s is provided by several MCP servers. Which one should be used?", name), names)
+	if !ok {
+		systemColor.Printf("Invalid choice, using %s.\n", names[0])
+		choice = 0
+	}
+	p.picked[name] = owners[choice]
+	return owners[choice], true
+}
+
+// enforceLimit stops the least recently used servers, never keep, until no
+// more than maxActive are running.
+func (p *mcpPool) enforceLimit(keep *mcpServer) {
+	if p.maxActive <= 0 {
+		return
+	}
+	for {
+		running := 0
+		var oldest *mcpServer
+		for _, server := range p.servers {
+			if !server.running {
+				continue
+			}
+			running++
+			if server != keep && (oldest == nil || server.lastUsed.Before(oldest.lastUsed)) {
+				oldest = server
+			}
+		}
+		if running <= p.maxActive || oldest == nil {
+			return
+		}
+		systemColor.Printf("Stopping MCP server %s (max_active_servers reached)\n", oldest.config.Name)
+		oldest.stop()
+	}
+}
+
+func (p *mcpPool) close() {
+	for _, server := range p.servers {
+		server.stop()
+	}
+}
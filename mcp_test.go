@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+)
+
+// TestServerPID guards the reflection in serverPID, written against
+// parakeet v0.2.6 and mcp-go v0.8.3: if a new version moves the process,
+// servers that stop answering would be abandoned instead of killed.
+func TestServerPID(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The test binary itself, running no tests, stands in for a server.
+	client, err := mcpstdio.NewClient(context.Background(), executable, nil, "-test.run=^$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if pid := serverPID(&client); pid <= 0 {
+		t.Fatalf("serverPID = %d: the server process is no longer at mcpClient.cmd.Process", pid)
+	}
+}
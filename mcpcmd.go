@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// mcpCommand shows the MCP server processes or restarts or kills one.
+func (app *App) mcpCommand(args []string) {
+	if app.mcp == nil || len(app.mcp.servers) == 0 {
+		systemColor.Println("No MCP servers are configured.")
+		return
+	}
+	switch {
+	case len(args) == 0 || args[0] == "status" && len(args) == 1:
+		app.mcp.showStatus()
+	case args[0] == "restart" && len(args) == 2:
+		app.mcp.restart(args[1])
+	case args[0] == "kill" && len(args) == 2:
+		app.mcp.kill(args[1])
+	default:
+		systemColor.Println("Usage: /mcp status|restart <name>|kill <name>")
+	}
+}
+
+// showStatus lists every server with its process, uptime, tool count and
+// whether it still answers.
+func (p *mcpPool) showStatus() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	toolColor.Printf("  %-20s %-8s %-10s %-6s %s\n", "Server", "PID", "Uptime", "Tools", "Health")
+	for _, server := range p.servers {
+		pid, uptime := "-", "-"
+		if server.running {
+			if server.pid != 0 {
+				pid = strconv.Itoa(server.pid)
+			}
+			uptime = time.Since(server.startedAt).Round(time.Second).String()
+		}
+		toolColor.Printf("  %-20s %-8s %-10s %-6d %s\n", server.config.Name, pid, uptime, p.toolCount(server), p.health(server))
+	}
+}
+
+func (p *mcpPool) toolCount(server *mcpServer) int {
+	count := 0
+	for _, owners := range p.toolOwners {
+		for _, owner := range owners {
+			if owner == server {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// health asks a running server for its tools to check that it answers. A
+// server that does not is killed, like one whose tool call timed out.
+func (p *mcpPool) health(server *mcpServer) string {
+	if !server.running {
+		return "stopped"
+	}
+	err := withTimeout(p.initTimeout, func() error {
+		_, err := server.client.ListTools()
+		return err
+	})
+	switch {
+	case errors.Is(err, errMCPTimeout):
+		server.kill()
+		return "not answering, killed"
+	case err != nil:
+		return "error: " + err.Error()
+	}
+	return "ok"
+}
+
+func (p *mcpPool) server(name string) (*mcpServer, bool) {
+	for _, server := range p.servers {
+		if server.config.Name == name {
+			return server, true
+		}
+	}
+	systemColor.Printf("Unknown MCP server %q.\n", name)
+	return nil, false
+}
+
+// restart stops a server and starts it again the way a tool call would.
+func (p *mcpPool) restart(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	server, ok := p.server(name)
+	if !ok {
+		return
+	}
+	server.stop()
+	if err := server.start(p.ctx, p.initTimeout); err != nil {
+		toolColor.Printf("Failed to restart MCP server %s: %v\n", name, err)
+		return
+	}
+	p.enforceLimit(server)
+	toolColor.Printf("Restarted MCP server %s.\n", name)
+}
+
+// kill stops a server at once. It is started again by the next call to
+// one of its tools.
+func (p *mcpPool) kill(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	server, ok := p.server(name)
+	if !ok {
+		return
+	}
+	if !server.running {
+		toolColor.Printf("MCP server %s is not running.\n", name)
+		return
+	}
+	server.kill()
+	toolColor.Printf("Killed MCP server %s.\n", name)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/llm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	turnsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lloms_turns_total",
+		Help: "Number of user turns processed.",
+	})
+	toolCallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lloms_tool_calls_total",
+		Help: "Number of MCP tool calls, by tool.",
+	}, []string{"tool"})
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lloms_errors_total",
+		Help: "Number of errors, by stage (turn, tools_query, tool_call, chat).",
+	}, []string{"stage"})
+	turnDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "lloms_turn_duration_seconds",
+		Help:    "Wall clock duration of a user turn.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	})
+	tokensPerSecond = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lloms_tokens_per_second",
+		Help:    "Generation speed reported by Ollama, by stage.",
+		Buckets: prometheus.LinearBuckets(5, 10, 12),
+	}, []string{"stage"})
+)
+
+// serveMetrics exposes the Prometheus registry on addr in the background.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			systemColor.Printf("Warning: Metrics endpoint stopped: %v\n", err)
+		}
+	}()
+}
+
+func observeTurn(start time.Time, err error) {
+	turnsTotal.Inc()
+	turnDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		errorsTotal.WithLabelValues("turn").Inc()
+	}
+}
+
+func observeCompletion(stage string, answer llm.Answer, err error) {
+	if err != nil {
+		errorsTotal.WithLabelValues(stage).Inc()
+		return
+	}
+	if answer.EvalDuration > 0 {
+		tokensPerSecond.WithLabelValues(stage).Observe(float64(answer.EvalCount) / time.Duration(answer.EvalDuration).Seconds())
+	}
+}
+
+func observeToolCall(name string, err error) {
+	toolCallsTotal.WithLabelValues(name).Inc()
+	if err != nil {
+		errorsTotal.WithLabelValues("tool_call").Inc()
+	}
+}
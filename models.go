@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+type pullProgress struct {
+	Status    string `json:"status"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+	Error     string `json:"error"`
+}
+
+// checkAPI pings the Ollama API to make sure the configured URL and base
+// path point at a server.
+func checkAPI(apiURL string) error {
+	resp, err := http.Get(apiURL + "/api/version")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s/api/version returned %s", apiURL, resp.Status)
+	}
+	return nil
+}
+
+// normalizeModelName adds the implicit ":latest" tag Ollama uses for
+// untagged model names.
+func normalizeModelName(model string) string {
+	if strings.Contains(model, ":") {
+		return model
+	}
+	return model + ":latest"
+}
+
+func modelInstalled(ollamaURL, model string) (bool, error) {
+	models, _, err := llm.GetModelsList(ollamaURL)
+	if err != nil {
+		return false, err
+	}
+	for _, installed := range models.Models {
+		if normalizeModelName(installed.Name) == normalizeModelName(model) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// pullModel pulls a model through Ollama's streaming pull API, drawing a
+// progress bar as layers are downloaded.
+func pullModel(ollamaURL, model string) error {
+	body, err := json.Marshal(map[string]any{"name": model, "stream": true})
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.Post(ollamaURL+"/api/pull", "application/json; charset=utf-8", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pull failed: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var progress pullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &progress); err != nil {
+			continue
+		}
+		if progress.Error != "" {
+			fmt.Println()
+			return fmt.Errorf("pull failed: %s", progress.Error)
+		}
+		if progress.Total > 0 {
+			systemColor.Printf("\r%s %s", progressBar(progress.Completed, progress.Total, 30), progress.Status)
+		} else {
+			systemColor.Printf("\r%-60s", progress.Status)
+		}
+	}
+	fmt.Println()
+	return scanner.Err()
+}
+
+func progressBar(completed, total int64, width int) string {
+	filled := int(completed * int64(width) / total)
+	if filled > width {
+		filled = width
+	}
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), completed*100/total)
+}
+
+// ensureModel makes sure a model is available locally, pulling it when
+// autoPull is set or the user agrees to it.
+func ensureModel(ollamaURL, model string, autoPull bool) error {
+	installed, err := modelInstalled(ollamaURL, model)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	if installed {
+		return nil
+	}
+
+	if !autoPull && !confirm(fmt.Sprintf("Model %s is not installed. Pull it now?", model)) {
+		systemColor.Printf("Warning: Model %s is not installed, requests using it will fail.\n", model)
+		return nil
+	}
+
+	systemColor.Printf("Pulling model %s...\n", model)
+	if err := pullModel(ollamaURL, model); err != nil {
+		return err
+	}
+	systemColor.Printf("Model %s pulled successfully.\n", model)
+	return nil
+}
+
+// maxModelSuggestions is how many installed models are suggested for a
+// model name that was not found.
+const maxModelSuggestions = 3
+
+// missingModelHint explains a "model not found" error from Ollama,
+// suggesting the installed models closest to the name. It reports false
+// for other errors.
+func missingModelHint(ollamaURL string, err error) (string, bool) {
+	var notFound *completion.ModelNotFoundError
+	if !errors.As(err, &notFound) {
+		return "", false
+	}
+	if suggestions := suggestModels(ollamaURL, notFound.Model); len(suggestions) > 0 {
+		return fmt.Sprintf("model %s was not found. Did you mean %s?", notFound.Model, strings.Join(suggestions, " or ")), true
+	}
+	return fmt.Sprintf("model %s was not found, pull it with: ollama pull %s", notFound.Model, notFound.Model), true
+}
+
+// suggestModels returns the installed models whose names are closest to
+// model, ignoring the ":latest" tag.
+func suggestModels(ollamaURL, model string) []string {
+	models, _, err := llm.GetModelsList(ollamaURL)
+	if err != nil {
+		return nil
+	}
+	type candidate struct {
+		name     string
+		distance int
+	}
+	wanted := normalizeModelName(model)
+	var candidates []candidate
+	for _, installed := range models.Models {
+		name := normalizeModelName(installed.Name)
+		distance := levenshtein(wanted, name)
+		if strings.Contains(name, strings.SplitN(wanted, ":", 2)[0]) {
+			distance = min(distance, 1)
+		}
+		if distance <= max(3, len(wanted)/3) {
+			candidates = append(candidates, candidate{installed.Name, distance})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+
+	var names []string
+	for _, candidate := range candidates[:min(len(candidates), maxModelSuggestions)] {
+		names = append(names, candidate.name)
+	}
+	return names
+}
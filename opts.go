@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/enums/option"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// sampleOptions lists the options /opts shows and can set, in display
+// order. Their names match the fields of llm.Options.
+var sampleOptions = []string{
+	option.Temperature,
+	option.TopK,
+	option.TopP,
+	option.MinP,
+	option.TypicalP,
+	option.TFSZ,
+	option.RepeatLastN,
+	option.RepeatPenalty,
+	option.PresencePenalty,
+	option.FrequencyPenalty,
+	option.PenalizeNewline,
+	option.Mirostat,
+	option.MirostatTau,
+	option.MirostatEta,
+	option.NumCtx,
+	option.NumPredict,
+	option.NumKeep,
+	option.Seed,
+}
+
+func (app *App) optsCommand(args []string) {
+	switch {
+	case len(args) == 0:
+		app.showOptions()
+	case args[0] == "reset" && len(args) == 1:
+		app.chatOptionOverrides = nil
+		app.toolsOptionOverrides = nil
+		systemColor.Println("Options set with /opts were reset.")
+	case args[0] == "set" && len(args) == 3:
+		app.setSampleOption(args[1], args[2])
+	default:
+		systemColor.Println("Usage: /opts [set [tools.]<option> <value>|reset]")
+	}
+}
+
+// showOptions prints the options sent with the next chat and tools
+// requests. Values set with /opts are marked with *.
+func (app *App) showOptions() {
+	chat := reflect.ValueOf(app.chatOptions())
+	tools := reflect.ValueOf(app.toolsOptions())
+	systemColor.Printf("  %-18s %-14s %s\n", "Option", "Chat", "Tools")
+	for _, key := range sampleOptions {
+		systemColor.Printf("  %-18s %-14s %s\n", key,
+			optionValue(chat.FieldByName(key), app.chatOptionOverrides[key] != nil),
+			optionValue(tools.FieldByName(key), app.toolsOptionOverrides[key] != nil))
+	}
+}
+
+func optionValue(field reflect.Value, overridden bool) string {
+	text := fmt.Sprint(field.Interface())
+	if overridden {
+		text += "*"
+	}
+	return text
+}
+
+// setSampleOption overrides one option of the chat model, or of the tools
+// model with a "tools." prefix, for the following requests.
+func (app *App) setSampleOption(key, text string) {
+	tools := false
+	if name, ok := strings.CutPrefix(key, "tools."); ok {
+		tools = true
+		key = name
+	}
+	name, ok := lookupSampleOption(key)
+	if !ok {
+		systemColor.Printf("Unknown option %q. Known options: %s\n", key, strings.Join(sampleOptions, ", "))
+		return
+	}
+	value, err := parseOptionValue(name, text)
+	if err != nil {
+		systemColor.Printf("Cannot set %s: %v\n", name, err)
+		return
+	}
+
+	overrides := &app.chatOptionOverrides
+	target := "chat"
+	if tools {
+		overrides = &app.toolsOptionOverrides
+		target = "tools"
+	}
+	if *overrides == nil {
+		*overrides = map[string]any{}
+	}
+	(*overrides)[name] = value
+	systemColor.Printf("%s set to %v for the %s model.\n", name, value, target)
+}
+
+// lookupSampleOption matches key against sampleOptions, ignoring case and
+// underscores so that both TopK and top_k are accepted.
+func lookupSampleOption(key string) (string, bool) {
+	normalized := strings.ToLower(strings.ReplaceAll(key, "_", ""))
+	for _, name := range sampleOptions {
+		if strings.ToLower(name) == normalized {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// parseOptionValue parses text into the type llm.SetOptions expects for
+// the option.
+func parseOptionValue(name, text string) (any, error) {
+	field, _ := reflect.TypeOf(llm.Options{}).FieldByName(name)
+	switch field.Type.Kind() {
+	case reflect.Int:
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", text)
+		}
+		return value, nil
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return value, nil
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", text)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unsupported option")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeTurnFile saves a turn's answer as <outDir>/<turn>-<timestamp>.md,
+// headed by the prompt. Existing files are never overwritten.
+func (app *App) writeTurnFile(prompt, response string) error {
+	if err := os.MkdirAll(app.outDir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%03d-%s", app.turn, time.Now().Format("20060102-150405"))
+	content := fmt.Sprintf("# %s\n\n%s\n", prompt, response)
+	for attempt := 0; ; attempt++ {
+		path := filepath.Join(app.outDir, name+".md")
+		if attempt > 0 {
+			path = filepath.Join(app.outDir, fmt.Sprintf("%s-%d.md", name, attempt))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := file.WriteString(content); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+}
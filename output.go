@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputYAML = "yaml"
+)
+
+type turnToolCall struct {
+	Name      string         `json:"name" yaml:"name"`
+	Arguments map[string]any `json:"arguments" yaml:"arguments"`
+	Error     string         `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type turnStats struct {
+	PromptTokens     int     `json:"prompt_tokens" yaml:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens" yaml:"completion_tokens"`
+	DurationSeconds  float64 `json:"duration_seconds" yaml:"duration_seconds"`
+	Cost             float64 `json:"cost,omitempty" yaml:"cost,omitempty"`
+}
+
+// turnOutput is the structured record written after each turn when an
+// output format other than text is selected.
+type turnOutput struct {
+	Model     string         `json:"model" yaml:"model"`
+	Content   string         `json:"content" yaml:"content"`
+	ToolCalls []turnToolCall `json:"tool_calls" yaml:"tool_calls"`
+	Stats     turnStats      `json:"stats" yaml:"stats"`
+}
+
+func validOutputFormat(format string) bool {
+	switch format {
+	case OutputText, OutputJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+func writeTurnOutput(w io.Writer, format string, output turnOutput) error {
+	if output.ToolCalls == nil {
+		output.ToolCalls = []turnToolCall{}
+	}
+	switch format {
+	case OutputJSON:
+		data, err := json.Marshal(output)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case OutputYAML:
+		data, err := yaml.Marshal(output)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "---\n%s", data)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// usePager reports whether responses should be buffered and shown through
+// the pager: only when enabled and writing to an interactive terminal.
+func (app *App) usePager() bool {
+	return app.pager && app.out == os.Stdout && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// showInPager pipes text through $PAGER (less by default). less is told to
+// exit straight away when the text fits on one screen.
+func showInPager(text string) error {
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "less"
+	}
+	fields := strings.Fields(pager)
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	return cmd.Run()
+}
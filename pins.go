@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// pinnedIndexes returns the positions of the pinned records, in order.
+func (app *App) pinnedIndexes(records []llm.MessageRecord) []int {
+	var pinned []int
+	for i, record := range records {
+		if app.pins[record.Id] {
+			pinned = append(pinned, i)
+		}
+	}
+	return pinned
+}
+
+// pinCommand pins or unpins the message at the given position, as shown by
+// /pins, /find and /search.
+func (app *App) pinCommand(arg string, pin bool) {
+	usage := "Usage: /pin <index>"
+	if !pin {
+		usage = "Usage: /unpin <index>"
+	}
+	index, err := strconv.Atoi(arg)
+	if err != nil {
+		systemColor.Println(usage)
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	if index < 0 || index >= len(records) {
+		systemColor.Printf("No message at index %d (0-%d).\n", index, len(records)-1)
+		return
+	}
+	id := records[index].Id
+	if pin {
+		if app.pins == nil {
+			app.pins = map[string]bool{}
+		}
+		app.pins[id] = true
+		systemColor.Printf("Pinned message %d.\n", index)
+		return
+	}
+	if !app.pins[id] {
+		systemColor.Printf("Message %d is not pinned.\n", index)
+		return
+	}
+	delete(app.pins, id)
+	systemColor.Printf("Unpinned message %d.\n", index)
+}
+
+func (app *App) listPins() {
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	pinned := app.pinnedIndexes(records)
+	if len(pinned) == 0 {
+		systemColor.Println("No pinned messages. Use /pin <index> to pin one.")
+		return
+	}
+	for _, i := range pinned {
+		systemColor.Printf("[%d] %s: ", i, records[i].Role)
+		fmt.Fprintln(app.out, messagePreview(records[i].Content))
+	}
+}
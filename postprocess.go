@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ToolPostProcessor cleans up the results of the tools whose names match
+// Tool, a glob pattern. Replacements run first, then the optional command,
+// which gets the result on stdin and replaces it with its stdout.
+type ToolPostProcessor struct {
+	Tool    string         `yaml:"tool"`
+	Replace []RegexReplace `yaml:"replace"`
+	Command string         `yaml:"command"`
+}
+
+type RegexReplace struct {
+	Pattern string `yaml:"pattern"`
+	With    string `yaml:"with"`
+}
+
+func validateToolPostProcessors(processors []ToolPostProcessor) error {
+	for i, processor := range processors {
+		if processor.Tool == "" {
+			return fmt.Errorf("tool_post_processors[%d]: tool is required", i)
+		}
+		if err := validateToolPatterns(fmt.Sprintf("tool_post_processors[%d].tool", i), []string{processor.Tool}); err != nil {
+			return err
+		}
+		for _, replace := range processor.Replace {
+			if _, err := regexp.Compile(replace.Pattern); err != nil {
+				return fmt.Errorf("invalid tool_post_processors[%d] pattern: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// postProcessToolResult applies every post-processor matching the tool, in
+// config order. A failing command leaves the result as it was.
+func (app *App) postProcessToolResult(toolName, result string) string {
+	for _, processor := range app.config.ToolPostProcessors {
+		if !matchesToolPattern(toolName, []string{processor.Tool}) {
+			continue
+		}
+		for _, replace := range processor.Replace {
+			result = regexp.MustCompile(replace.Pattern).ReplaceAllString(result, replace.With)
+		}
+		if processor.Command == "" {
+			continue
+		}
+		output, err := runShell(processor.Command, result)
+		if err != nil {
+			systemColor.Printf("Warning: Post-processor for %s failed, using the result as is: %v\n", toolName, err)
+			continue
+		}
+		result = output
+	}
+	return result
+}
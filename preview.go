@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+)
+
+const (
+	ToolPreviewFileDiff = "file_diff"
+
+	// previewContextLines is the number of unchanged lines kept around each
+	// change in a file diff preview.
+	previewContextLines = 2
+)
+
+// ToolPreview shows what a tool call matching Tool will do before it is
+// confirmed. Type selects the previewer; the file_diff previewer reads the
+// target path and the new content from the PathArg and ContentArg
+// arguments.
+type ToolPreview struct {
+	Tool       string `yaml:"tool"`
+	Type       string `yaml:"type"`
+	PathArg    string `yaml:"path_arg"`
+	ContentArg string `yaml:"content_arg"`
+}
+
+// toolPreviewers maps each preview type to the function rendering it. The
+// returned lines are printed as they are.
+var toolPreviewers = map[string]func(preview ToolPreview, arguments map[string]any) ([]string, error){
+	ToolPreviewFileDiff: fileDiffPreview,
+}
+
+func validateToolPreviews(previews []ToolPreview) error {
+	for i := range previews {
+		preview := &previews[i]
+		if err := validateToolPatterns("tool_previews", []string{preview.Tool}); err != nil {
+			return err
+		}
+		if preview.Type == "" {
+			preview.Type = ToolPreviewFileDiff
+		}
+		if _, ok := toolPreviewers[preview.Type]; !ok {
+			return fmt.Errorf("tool_previews[%d]: unknown type %q", i, preview.Type)
+		}
+		if preview.PathArg == "" {
+			preview.PathArg = "path"
+		}
+		if preview.ContentArg == "" {
+			preview.ContentArg = "content"
+		}
+	}
+	return nil
+}
+
+// confirmTool asks before running a tool matching confirm_tools, showing
+// the preview of the first matching tool_previews entry.
+func (app *App) confirmTool(name string, arguments map[string]any) bool {
+	toolColor.Printf("%s The model wants to call %s with args: %v\n", iconTool, name, arguments)
+	for _, preview := range app.config.ToolPreviews {
+		if !matchesToolPattern(name, []string{preview.Tool}) {
+			continue
+		}
+		lines, err := toolPreviewers[preview.Type](preview, arguments)
+		if err != nil {
+			systemColor.Printf("No preview available: %v\n", err)
+		}
+		for _, line := range lines {
+			toolColor.Println(line)
+		}
+		break
+	}
+	return confirm(fmt.Sprintf("Run tool %s?", name))
+}
+
+// declineTool returns the result the model sees when the user declines a
+// tool call.
+func declineTool(name string) mcpstdio.CallToolResult {
+	systemColor.Printf("Tool %s was not run.\n", name)
+	return mcpstdio.CallToolResult{
+		Type: "text",
+		Text: fmt.Sprintf("The user declined to run the tool %s.", name),
+	}
+}
+
+// fileDiffPreview diffs the current content of the target file against the
+// content the tool is about to write.
+func fileDiffPreview(preview ToolPreview, arguments map[string]any) ([]string, error) {
+	path, ok := arguments[preview.PathArg].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument %q is missing", preview.PathArg)
+	}
+	content, ok := arguments[preview.ContentArg].(string)
+	if !ok {
+		return nil, fmt.Errorf("argument %q is missing", preview.ContentArg)
+	}
+
+	current, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return append([]string{"+++ " + path + " (new file)"}, diffLines(nil, strings.Split(content, "\n"))...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if string(current) == content {
+		return []string{path + " is unchanged"}, nil
+	}
+	diff := diffLines(strings.Split(string(current), "\n"), strings.Split(content, "\n"))
+	return append([]string{"--- " + path, "+++ " + path + " (proposed)"}, compactDiff(diff, previewContextLines)...), nil
+}
+
+// compactDiff drops the unchanged lines of a diffLines result that are more
+// than context lines away from a change.
+func compactDiff(diff []string, context int) []string {
+	keep := make([]bool, len(diff))
+	for i, line := range diff {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+		for j := max(i-context, 0); j <= min(i+context, len(diff)-1); j++ {
+			keep[j] = true
+		}
+	}
+	var compact []string
+	skipped := false
+	for i, line := range diff {
+		if !keep[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			compact = append(compact, "  ...")
+			skipped = false
+		}
+		compact = append(compact, line)
+	}
+	if skipped {
+		compact = append(compact, "  ...")
+	}
+	return compact
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// progressEvent is one line of the --progress-file: a step of the agent
+// loop and what happened in it.
+type progressEvent struct {
+	Step      int       `json:"step"`
+	Action    string    `json:"action"`
+	Tool      string    `json:"tool,omitempty"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Actions and statuses of progress events.
+const (
+	progressToolsCheck = "tools_check"
+	progressToolCall   = "tool_call"
+	progressAnswer     = "answer"
+	progressTurn       = "turn"
+
+	progressStarted  = "started"
+	progressNoTool   = "no_tool"
+	progressOK       = "ok"
+	progressFailed   = "failed"
+	progressBlocked  = "blocked"
+	progressDeclined = "declined"
+	progressDone     = "done"
+)
+
+// writeProgress appends an event for the current agent step to the
+// --progress-file, if any. The file is not buffered, so every event can
+// be read as soon as it is written.
+func (app *App) writeProgress(action, tool, status string) {
+	if app.progressFile == nil {
+		return
+	}
+	line, err := json.Marshal(progressEvent{
+		Step:      app.agentStep,
+		Action:    action,
+		Tool:      tool,
+		Status:    status,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return
+	}
+	if _, err := app.progressFile.Write(append(line, '\n')); err != nil {
+		systemColor.Printf("Warning: Failed to write progress file, disabling it: %v\n", err)
+		app.progressFile.Close()
+		app.progressFile = nil
+	}
+}
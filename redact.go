@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	redactedText = "[REDACTED]"
+	// redactHoldBack is how much streamed text is held back so a secret
+	// split across chunks is still matched as a whole.
+	redactHoldBack = 128
+)
+
+func validateRedactPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// redactor replaces matches of the configured patterns. A nil redactor
+// leaves text unchanged.
+type redactor struct {
+	patterns []*regexp.Regexp
+}
+
+func newRedactor(patterns []string) *redactor {
+	if len(patterns) == 0 {
+		return nil
+	}
+	r := &redactor{}
+	for _, pattern := range patterns {
+		r.patterns = append(r.patterns, regexp.MustCompile(pattern))
+	}
+	return r
+}
+
+func (r *redactor) redact(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllLiteralString(text, redactedText)
+	}
+	return text
+}
+
+// redactStream redacts streamed text, holding back the tail of what it has
+// seen so far until more text arrives or the stream is flushed.
+type redactStream struct {
+	redactor *redactor
+	pending  string
+}
+
+// write adds a chunk and returns the redacted text that is safe to show.
+func (s *redactStream) write(chunk string) string {
+	if s.redactor == nil {
+		return chunk
+	}
+	s.pending += chunk
+	cut := len(s.pending) - redactHoldBack
+	if cut <= 0 {
+		return ""
+	}
+	for cut > 0 && !utf8.RuneStart(s.pending[cut]) {
+		cut--
+	}
+	// Never split a match that is already complete.
+	for _, pattern := range s.redactor.patterns {
+		for _, loc := range pattern.FindAllStringIndex(s.pending, -1) {
+			if loc[0] < cut && cut < loc[1] {
+				cut = loc[0]
+			}
+		}
+	}
+	shown := s.redactor.redact(s.pending[:cut])
+	s.pending = s.pending[cut:]
+	return shown
+}
+
+// flush returns the redacted text still held back.
+func (s *redactStream) flush() string {
+	shown := s.redactor.redact(s.pending)
+	s.pending = ""
+	return shown
+}
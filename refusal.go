@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultRefusalPattern     = `(?i)\b(I can(no|')t (help|assist|do)|I'm (not able|unable) to|I am (not able|unable) to|I won't be able to|as an AI)\b`
+	defaultRefusalInstruction = "Your previous answer did not address the request. Please answer it directly and completely."
+)
+
+// RefusalRetryConfig retries a chat answer once, with an extra instruction,
+// when it looks like a refusal or is shorter than MinLength runes.
+type RefusalRetryConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	Pattern     string `yaml:"pattern"`
+	MinLength   int    `yaml:"min_length"`
+	Instruction string `yaml:"instruction"`
+}
+
+func (r *RefusalRetryConfig) validate() error {
+	if r.Pattern == "" {
+		r.Pattern = defaultRefusalPattern
+	}
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid refusal_retry.pattern: %w", err)
+	}
+	if r.MinLength < 0 {
+		return fmt.Errorf("invalid refusal_retry.min_length %d: must not be negative", r.MinLength)
+	}
+	if r.Instruction == "" {
+		r.Instruction = defaultRefusalInstruction
+	}
+	return nil
+}
+
+func (r RefusalRetryConfig) isRefusal(response string) bool {
+	if !r.Enabled {
+		return false
+	}
+	response = strings.TrimSpace(response)
+	if len([]rune(response)) < r.MinLength {
+		return true
+	}
+	return regexp.MustCompile(r.Pattern).MatchString(response)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+type replayOptions struct {
+	session string
+	model   string
+	out     string
+}
+
+type replayTurn struct {
+	Prompt   string `json:"prompt"`
+	Original string `json:"original"`
+	Replayed string `json:"replayed"`
+}
+
+type replayReport struct {
+	Session string       `json:"session"`
+	Model   string       `json:"model"`
+	Turns   []replayTurn `json:"turns"`
+}
+
+// parseReplayArgs parses "replay <session.json> [--model <m>] [--out <file>]",
+// accepting the flags before or after the session file.
+func parseReplayArgs(args []string, defaultModel string) (replayOptions, error) {
+	var options replayOptions
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.StringVar(&options.model, "model", defaultModel, "")
+	flags.StringVar(&options.out, "out", "", "")
+
+	session, err := parseSessionArgs(flags, args)
+	if err != nil {
+		return options, err
+	}
+	options.session = session
+	return options, nil
+}
+
+// replayTurns pairs each user prompt of a transcript with the answer it
+// originally got, skipping the tool results stored as user messages.
+func replayTurns(messages []llm.Message) []replayTurn {
+	var turns []replayTurn
+	for i, message := range messages {
+		switch {
+		case message.Role == RoleUser && (i == 0 || !isToolUseMessage(messages[i-1])):
+			turns = append(turns, replayTurn{Prompt: message.Content})
+		case message.Role == RoleAssistant && !isToolUseMessage(message) && len(turns) > 0:
+			turns[len(turns)-1].Original = message.Content
+		}
+	}
+	return turns
+}
+
+// replay feeds the user messages of a saved session, one by one, to a fresh
+// conversation with another model and shows each new answer next to the
+// original one.
+func (app *App) replay(options replayOptions) error {
+	session, err := readSessionFile(options.session)
+	if err != nil {
+		return err
+	}
+	turns := replayTurns(session.Messages)
+	if len(turns) == 0 {
+		return fmt.Errorf("%s has no user messages", options.session)
+	}
+	if err := app.resetConversation(); err != nil {
+		return err
+	}
+
+	systemColor.Printf("Replaying %d messages from %s with %s\n", len(turns), options.session, options.model)
+	for i := range turns {
+		userColor.Printf("You: ")
+		fmt.Fprintln(app.out, turns[i].Prompt)
+		_, err := app.runTurn(app.ctx, turns[i].Prompt, options.model, turnNew)
+		if err != nil && !errors.Is(err, errTurnAborted) {
+			return err
+		}
+		if records, err := app.conversation.GetAll(); err == nil {
+			turns[i].Replayed, _ = lastAssistantMessage(messagesOf(records))
+		}
+		systemColor.Println("Original answer:")
+		fmt.Fprintln(app.out, turns[i].Original)
+		fmt.Fprintln(app.out)
+	}
+
+	if options.out == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(replayReport{Session: options.session, Model: options.model, Turns: turns}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(options.out, data, 0644); err != nil {
+		return err
+	}
+	systemColor.Printf("Saved replay report to %s\n", options.out)
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+// resetSession starts over without leaving lloms: a new session with an
+// empty history, the on_start output taken again unless the command runs
+// in the background, and none of the overlays, variables, branches or
+// overrides of the old one. The config is kept. With reconnect the MCP
+// servers are started again.
+func (app *App) resetSession(args string) {
+	reconnect := false
+	switch args {
+	case "":
+	case "--mcp":
+		reconnect = true
+	default:
+		systemColor.Println("Usage: /reset [--mcp]")
+		return
+	}
+	if !confirm("Reset the session? The history and everything set during it will be lost.") {
+		systemColor.Println("Reset cancelled.")
+		return
+	}
+
+	if app.config.SaveSessions {
+		if err := app.saveSession(); err != nil {
+			systemColor.Printf("Warning: Failed to save session: %v\n", err)
+		}
+	}
+	if err := app.startNewSession(); err != nil {
+		systemColor.Printf("Failed to reset the session: %v\n", err)
+		return
+	}
+	app.overlays = nil
+	app.branches = nil
+	app.vars = nil
+	app.lastUserInput = ""
+	app.lastToolName = ""
+	app.lastToolResult = ""
+	app.chatOptionOverrides = nil
+	app.toolsOptionOverrides = nil
+	app.responseFormat = responseFormat{}
+	app.toolsPaused = false
+	app.sessionCost = 0
+	app.budgetWarned = false
+
+	if reconnect {
+		systemColor.Println("Reconnecting MCP servers...")
+		app.closeMCP()
+		if err := app.initMCP(); err != nil {
+			systemColor.Printf("Warning: Failed to initialize MCP client: %v\n", err)
+			app.closeMCP()
+		}
+	}
+	// A background on_start command is still running from the start.
+	if !app.config.OnStart.Background {
+		app.startupContext = ""
+		app.runOnStart()
+	}
+	systemColor.Println("Session reset.")
+}
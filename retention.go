@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const (
+	RetentionExchanges = "exchanges"
+	RetentionTail      = "tail"
+	RetentionWeighted  = "weighted"
+
+	// roleTool is the pseudo role used for retention weights of tool
+	// results, which are stored as user messages.
+	roleTool = "tool"
+)
+
+type RetentionConfig struct {
+	Policy  string             `yaml:"policy"`
+	Weights map[string]float64 `yaml:"weights"`
+}
+
+func (r RetentionConfig) weight(role string) float64 {
+	if weight, ok := r.Weights[role]; ok {
+		return weight
+	}
+	return 1
+}
+
+func (r RetentionConfig) validate() error {
+	switch r.Policy {
+	case "", RetentionExchanges, RetentionTail, RetentionWeighted:
+	default:
+		return fmt.Errorf("invalid retention.policy %q: expected %q, %q or %q", r.Policy, RetentionExchanges, RetentionTail, RetentionWeighted)
+	}
+	for role, weight := range r.Weights {
+		if weight < 0 {
+			return fmt.Errorf("invalid retention weight for %q: must not be negative", role)
+		}
+	}
+	return nil
+}
+
+// isToolUseMessage reports whether message is the assistant note that
+// precedes an injected tool result.
+func isToolUseMessage(message llm.Message) bool {
+	prefix, suffix, _ := strings.Cut(toolUsedFormat, "%s")
+	return message.Role == RoleAssistant &&
+		strings.HasPrefix(message.Content, prefix) &&
+		strings.HasSuffix(message.Content, suffix)
+}
+
+// retentionRole returns the role used to weigh messages[i], treating tool
+// results and their preceding note as the tool pseudo role.
+func retentionRole(messages []llm.Message, i int) string {
+	if isToolUseMessage(messages[i]) {
+		return roleTool
+	}
+	if messages[i].Role == RoleUser && i > 0 && isToolUseMessage(messages[i-1]) {
+		return roleTool
+	}
+	return messages[i].Role
+}
+
+// isExchangeStart reports whether messages[i] opens an exchange: a user
+// message that is not a tool result or a part of one.
+func isExchangeStart(messages []llm.Message, i int) bool {
+	return messages[i].Role == RoleUser &&
+		retentionRole(messages, i) != roleTool &&
+		!strings.HasPrefix(messages[i].Content, "[part ")
+}
+
+// alignToExchange moves start forward to the first message of an exchange,
+// so that trimming never keeps an answer or tool result without the
+// question it belongs to. When no exchange starts at or after start, it
+// moves back to the start of the last one instead.
+func alignToExchange(messages []llm.Message, start int) int {
+	for i := max(start, 0); i < len(messages); i++ {
+		if isExchangeStart(messages, i) {
+			return i
+		}
+	}
+	for i := min(start, len(messages)) - 1; i >= 0; i-- {
+		if isExchangeStart(messages, i) {
+			return i
+		}
+	}
+	return start
+}
+
+// tailIndexes returns the indexes from start to the end of messages.
+func tailIndexes(messages []llm.Message, start int) []int {
+	keep := make([]int, 0, len(messages)-start)
+	for i := start; i < len(messages); i++ {
+		keep = append(keep, i)
+	}
+	return keep
+}
+
+// weightedIndexes returns the indexes of the limit messages with the
+// highest weight, scaled by recency so that ties favour newer messages. The
+// latest message is always kept and the indexes are in ascending order.
+func weightedIndexes(messages []llm.Message, limit int, retention RetentionConfig) []int {
+	last := len(messages) - 1
+	candidates := make([]int, 0, last)
+	for i := 0; i < last; i++ {
+		candidates = append(candidates, i)
+	}
+
+	score := func(i int) float64 {
+		return retention.weight(retentionRole(messages, i)) * float64(i+1) / float64(len(messages))
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return score(candidates[a]) > score(candidates[b])
+	})
+
+	keep := append(candidates[:max(limit-1, 0)], last)
+	sort.Ints(keep)
+	return keep
+}
+
+// lastToolResult returns the bounds of the most recent tool result: the
+// assistant note and every part of the result that follows it. ok is false
+// when the messages hold no tool result.
+func lastToolResult(messages []llm.Message) (start, end int, ok bool) {
+	for i := len(messages) - 2; i >= 0; i-- {
+		if !isToolUseMessage(messages[i]) || messages[i+1].Role != RoleUser {
+			continue
+		}
+		end = i + 2
+		for end < len(messages) && messages[end].Role == RoleUser && strings.HasPrefix(messages[end].Content, "[part ") {
+			end++
+		}
+		return i, end, true
+	}
+	return 0, 0, false
+}
+
+// pinLastToolResult adds the indexes of the most recent tool result to
+// keep, so trimming never hides what a tool just returned.
+func pinLastToolResult(messages []llm.Message, keep []int) []int {
+	start, end, ok := lastToolResult(messages)
+	if !ok {
+		return keep
+	}
+	for i := start; i < end; i++ {
+		keep = addIndex(keep, i)
+	}
+	return keep
+}
+
+// addIndex adds i to the sorted indexes in keep, unless already there.
+func addIndex(keep []int, i int) []int {
+	pos, found := slices.BinarySearch(keep, i)
+	if found {
+		return keep
+	}
+	return slices.Insert(keep, pos, i)
+}
+
+// estimateTokens roughly counts the tokens of text, at four bytes a token.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// tokenBudgetIndexes returns the indexes of the newest messages whose
+// estimated tokens fit in budget. The latest message is always kept.
+func tokenBudgetIndexes(messages []llm.Message, budget int) []int {
+	if len(messages) == 0 {
+		return nil
+	}
+	start := len(messages) - 1
+	used := estimateTokens(messages[start].Content)
+	for start > 0 && used+estimateTokens(messages[start-1].Content) <= budget {
+		start--
+		used += estimateTokens(messages[start].Content)
+	}
+	return tailIndexes(messages, start)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// retentionHistory is a conversation with two exchanges, the first of which
+// called a tool whose result was split into two parts. The estimated token
+// count of each message is given alongside.
+var retentionHistory = []llm.Message{
+	{Role: RoleUser, Content: "q1"},                                      // 0: 1 token
+	{Role: RoleAssistant, Content: "a1"},                                 // 1: 1 token
+	{Role: RoleAssistant, Content: fmt.Sprintf(toolUsedFormat, "clock")}, // 2: 9 tokens
+	{Role: RoleUser, Content: "result"},                                  // 3: 2 tokens
+	{Role: RoleUser, Content: "[part 2/2] more"},                         // 4: 4 tokens
+	{Role: RoleAssistant, Content: "a2"},                                 // 5: 1 token
+	{Role: RoleUser, Content: "q2"},                                      // 6: 1 token
+	{Role: RoleAssistant, Content: "a3"},                                 // 7: 1 token
+}
+
+// pick returns the messages of retentionHistory at indexes.
+func pick(indexes ...int) []llm.Message {
+	messages := make([]llm.Message, 0, len(indexes))
+	for _, i := range indexes {
+		messages = append(messages, retentionHistory[i])
+	}
+	return messages
+}
+
+func TestAlignToExchange(t *testing.T) {
+	answers := []llm.Message{
+		{Role: RoleAssistant, Content: "a1"},
+		{Role: RoleAssistant, Content: "a2"},
+	}
+	tests := []struct {
+		name     string
+		messages []llm.Message
+		start    int
+		want     int
+	}{
+		{"already at an exchange", retentionHistory, 0, 0},
+		{"answer moves to the next question", retentionHistory, 1, 6},
+		{"tool note moves to the next question", retentionHistory, 2, 6},
+		{"tool result is not an exchange", retentionHistory, 3, 6},
+		{"result part is not an exchange", retentionHistory, 4, 6},
+		{"negative start", retentionHistory, -1, 0},
+		{"falls back to the previous exchange", retentionHistory, 7, 6},
+		{"falls back from past the end", retentionHistory, len(retentionHistory), 6},
+		{"no exchange at all", answers, 1, 1},
+		{"empty history", nil, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := alignToExchange(test.messages, test.start); got != test.want {
+				t.Errorf("alignToExchange(%d) = %d, want %d", test.start, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetLastMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		messages []llm.Message
+		pinned   []int
+		want     []llm.Message
+	}{
+		{
+			name:     "unlimited window",
+			config:   Config{HistoryWindow: -1},
+			messages: retentionHistory,
+			want:     retentionHistory,
+		},
+		{
+			name:     "window larger than the history",
+			config:   Config{HistoryWindow: 10},
+			messages: retentionHistory,
+			want:     retentionHistory,
+		},
+		{
+			name:     "window aligned to the next exchange",
+			config:   Config{HistoryWindow: 4},
+			messages: retentionHistory,
+			want:     pick(6, 7),
+		},
+		{
+			name:     "window as large as the history",
+			config:   Config{HistoryWindow: 8},
+			messages: retentionHistory,
+			want:     retentionHistory,
+		},
+		{
+			name:     "window falls back to the previous exchange",
+			config:   Config{HistoryWindow: 1},
+			messages: retentionHistory,
+			want:     pick(6, 7),
+		},
+		{
+			name:     "window with tail policy cuts anywhere",
+			config:   Config{HistoryWindow: 4, Retention: RetentionConfig{Policy: RetentionTail}},
+			messages: retentionHistory,
+			want:     pick(4, 5, 6, 7),
+		},
+		{
+			name:     "window keeps the last tool result and its parts",
+			config:   Config{HistoryWindow: 2, AlwaysKeepLastToolResult: true},
+			messages: retentionHistory,
+			want:     pick(2, 3, 4, 6, 7),
+		},
+		{
+			name:     "window keeps pinned messages",
+			config:   Config{HistoryWindow: 2},
+			messages: retentionHistory,
+			pinned:   []int{0, 42},
+			want:     pick(0, 6, 7),
+		},
+		{
+			name:     "tokens fitting the whole history",
+			config:   Config{HistoryTokens: 100},
+			messages: retentionHistory,
+			want:     retentionHistory,
+		},
+		{
+			// The budget reaches back to the answer before the last
+			// question, which is dropped so the history starts with it.
+			name:     "tokens aligned to the next exchange",
+			config:   Config{HistoryTokens: 3},
+			messages: retentionHistory,
+			want:     pick(6, 7),
+		},
+		{
+			name:     "tokens with tail policy cut anywhere",
+			config:   Config{HistoryTokens: 3, Retention: RetentionConfig{Policy: RetentionTail}},
+			messages: retentionHistory,
+			want:     pick(5, 6, 7),
+		},
+		{
+			name:     "tokens cutting into a tool result",
+			config:   Config{HistoryTokens: 10},
+			messages: retentionHistory,
+			want:     pick(6, 7),
+		},
+		{
+			name:     "tokens keep the last tool result and its parts",
+			config:   Config{HistoryTokens: 10, AlwaysKeepLastToolResult: true},
+			messages: retentionHistory,
+			want:     pick(2, 3, 4, 6, 7),
+		},
+		{
+			// Only the last answer fits: the question it answers is kept
+			// anyway.
+			name:     "tokens fall back to the previous exchange",
+			config:   Config{HistoryTokens: 1},
+			messages: retentionHistory,
+			want:     pick(6, 7),
+		},
+		{
+			name:     "tokens on an empty history",
+			config:   Config{HistoryTokens: 5},
+			messages: []llm.Message{},
+			want:     []llm.Message{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getLastMessages(test.messages, test.config, test.pinned)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("getLastMessages =\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenBudgetIndexes(t *testing.T) {
+	tests := []struct {
+		budget int
+		want   []int
+	}{
+		{budget: 0, want: []int{7}},
+		{budget: 3, want: []int{5, 6, 7}},
+		{budget: 10, want: []int{3, 4, 5, 6, 7}},
+		{budget: 100, want: []int{0, 1, 2, 3, 4, 5, 6, 7}},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.budget), func(t *testing.T) {
+			got := tokenBudgetIndexes(retentionHistory, test.budget)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("tokenBudgetIndexes(%d) = %v, want %v", test.budget, got, test.want)
+			}
+		})
+	}
+	if got := tokenBudgetIndexes(nil, 10); len(got) != 0 {
+		t.Errorf("tokenBudgetIndexes(nil) = %v, want none", got)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// RoleMap renames the system, user and assistant roles for backends that
+// expect other role names. Roles without an entry are sent as they are.
+type RoleMap map[string]string
+
+func (m RoleMap) validate() error {
+	for role, name := range m {
+		switch role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			return fmt.Errorf("invalid role_map key %q: expected %q, %q or %q", role, RoleSystem, RoleUser, RoleAssistant)
+		}
+		if name == "" {
+			return fmt.Errorf("invalid role_map.%s: must not be empty", role)
+		}
+	}
+	return nil
+}
+
+// outgoing renames the roles of messages, which must not share their
+// backing array with the stored history.
+func (m RoleMap) outgoing(messages []llm.Message) []llm.Message {
+	if len(m) == 0 {
+		return messages
+	}
+	for i, message := range messages {
+		if name, ok := m[message.Role]; ok {
+			messages[i].Role = name
+		}
+	}
+	return messages
+}
+
+// incoming maps a backend role name back to the internal role.
+func (m RoleMap) incoming(name string) string {
+	for role, mapped := range m {
+		if mapped == name {
+			return role
+		}
+	}
+	return name
+}
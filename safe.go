@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+)
+
+// defaultSafeModeToolPatterns are glob patterns for tool names that write,
+// delete or execute, blocked in safe mode when no patterns are configured.
+var defaultSafeModeToolPatterns = []string{
+	"*write*", "*edit*", "*create*", "*delete*", "*remove*", "*move*", "*rename*",
+	"*exec*", "*run*", "*shell*", "*command*", "*kill*",
+}
+
+func validateToolPatterns(key string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s pattern %q: %w", key, pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesToolPattern reports whether name matches one of the glob patterns,
+// ignoring case.
+func matchesToolPattern(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// safeModeBlocks reports whether safe mode forbids calling the tool.
+func (app *App) safeModeBlocks(name string) bool {
+	if !app.safeMode {
+		return false
+	}
+	patterns := app.config.SafeModeToolPatterns
+	if len(patterns) == 0 {
+		patterns = defaultSafeModeToolPatterns
+	}
+	return matchesToolPattern(name, patterns)
+}
+
+// refuseTool logs a blocked call and returns the refusal the model sees in
+// place of the tool result.
+func refuseTool(name string, arguments map[string]any) mcpstdio.CallToolResult {
+	systemColor.Printf("%s %s Safe mode blocked tool %s with args: %v\n", time.Now().Format(time.RFC3339), iconBlocked, name, arguments)
+	return mcpstdio.CallToolResult{
+		Type: "text",
+		Text: fmt.Sprintf("The tool %s is not available: it is blocked in safe mode. Do not try to call it again.", name),
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// searchContextRunes is how much text is shown on each side of a match.
+const searchContextRunes = 40
+
+type searchHit struct {
+	sessionID string
+	index     int
+	role      string
+	content   string
+}
+
+// searchCommand lists the messages of the conversation containing the
+// query, ignoring case. With --all and the sqlite backend, every stored
+// session is searched.
+func (app *App) searchCommand(args string) {
+	query, all := strings.CutPrefix(strings.TrimSpace(args), "--all")
+	query = strings.TrimSpace(query)
+	if query == "" {
+		systemColor.Println("Usage: /search [--all] <text>")
+		return
+	}
+
+	var hits []searchHit
+	if all {
+		store, ok := app.conversation.(*sqliteStore)
+		if !ok {
+			systemColor.Println("Searching all sessions needs storage_backend: sqlite.")
+			return
+		}
+		var err error
+		hits, err = store.searchAll(query)
+		if err != nil {
+			systemColor.Printf("Search failed: %v\n", err)
+			return
+		}
+	} else {
+		records, err := app.conversation.GetAll()
+		if err != nil {
+			systemColor.Printf("Failed to read conversation: %v\n", err)
+			return
+		}
+		for i, record := range records {
+			if strings.Contains(strings.ToLower(record.Content), strings.ToLower(query)) {
+				hits = append(hits, searchHit{sessionID: app.sessionID, index: i, role: record.Role, content: record.Content})
+			}
+		}
+	}
+
+	if len(hits) == 0 {
+		systemColor.Printf("No messages contain %q.\n", query)
+		return
+	}
+	for _, hit := range hits {
+		if all {
+			systemColor.Printf("%s [%d] %s: ", hit.sessionID, hit.index, hit.role)
+		} else {
+			systemColor.Printf("[%d] %s: ", hit.index, hit.role)
+		}
+		fmt.Fprintln(app.out, searchSnippet(hit.content, query))
+	}
+	systemColor.Printf("%d matching messages.\n", len(hits))
+}
+
+// searchSnippet returns the text around the first match of query in
+// content, on a single line.
+func searchSnippet(content, query string) string {
+	runes := []rune(strings.Join(strings.Fields(content), " "))
+	lower := []rune(strings.ToLower(string(runes)))
+	match := strings.Index(string(lower), strings.ToLower(query))
+	if match < 0 || len(lower) != len(runes) {
+		return messagePreview(content)
+	}
+	start := len([]rune(string(lower)[:match]))
+	end := start + len([]rune(query))
+
+	snippet := string(runes[max(start-searchContextRunes, 0):min(end+searchContextRunes, len(runes))])
+	if start > searchContextRunes {
+		snippet = "…" + snippet
+	}
+	if end+searchContextRunes < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
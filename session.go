@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/enums/option"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const (
+	SessionTitleTruncate = "truncate"
+	SessionTitleModel    = "model"
+	sessionTitleMaxRunes = 60
+)
+
+// sessionFile is the JSON document a session is saved as.
+type sessionFile struct {
+	ID        string        `json:"id"`
+	Title     string        `json:"title"`
+	Model     string        `json:"model"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Messages  []llm.Message `json:"messages"`
+	// Tags maps message positions to their tags.
+	Tags map[int][]string `json:"tags,omitempty"`
+	// Pins lists the positions of the pinned messages.
+	Pins []int `json:"pins,omitempty"`
+	// Vars holds the variables set with /var.
+	Vars map[string]string `json:"vars,omitempty"`
+}
+
+func defaultSessionsDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".lloms", "sessions")
+	}
+	return filepath.Join(home, ".lloms", "sessions")
+}
+
+func sessionsDir(config Config) string {
+	if config.SessionsDir != "" {
+		return config.SessionsDir
+	}
+	return defaultSessionsDir()
+}
+
+func readSessionFile(path string) (sessionFile, error) {
+	var session sessionFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return session, err
+	}
+	if err := json.Unmarshal(data, &session); err != nil {
+		return session, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return session, nil
+}
+
+func writeSessionFile(path string, session sessionFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so an interrupted save never leaves a broken file.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func firstUserMessage(messages []llm.Message) (string, bool) {
+	for _, message := range messages {
+		if message.Role == RoleUser {
+			return message.Content, true
+		}
+	}
+	return "", false
+}
+
+// truncateTitle turns text into a single line of at most
+// sessionTitleMaxRunes runes.
+func truncateTitle(text string) string {
+	title := strings.Join(strings.Fields(text), " ")
+	runes := []rune(title)
+	if len(runes) <= sessionTitleMaxRunes {
+		return title
+	}
+	return strings.TrimSpace(string(runes[:sessionTitleMaxRunes-1])) + "…"
+}
+
+// sessionTitle derives a title from the first user message, asking the chat
+// model for one when session_title is "model" and falling back to a
+// truncation of the message.
+func (app *App) sessionTitle(messages []llm.Message) string {
+	first, ok := firstUserMessage(messages)
+	if !ok {
+		return ""
+	}
+	if app.config.SessionTitle == SessionTitleModel {
+		answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+			Model: app.config.ChatModel,
+			Messages: app.config.RoleMap.outgoing([]llm.Message{
+				{Role: RoleSystem, Content: "Reply with a short title of at most six words for a conversation starting with the user's message. Reply with the title only."},
+				{Role: RoleUser, Content: first},
+			}),
+			Options: llm.SetOptions(map[string]any{option.Temperature: 0.2}),
+		})
+		if err == nil {
+			if title := truncateTitle(strings.Trim(answer.Message.Content, "\"' \n")); title != "" {
+				return title
+			}
+		}
+		systemColor.Printf("Warning: Failed to generate a session title: %v\n", err)
+	}
+	return truncateTitle(first)
+}
+
+// saveSession writes the conversation to the session file, titling the
+// session the first time it has a user message.
+func (app *App) saveSession() error {
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		return err
+	}
+	messages := messagesOf(records)
+	if _, ok := firstUserMessage(messages); !ok {
+		return nil
+	}
+	if app.sessionTitleText == "" {
+		app.sessionTitleText = app.sessionTitle(messages)
+	}
+
+	path := filepath.Join(sessionsDir(app.config), app.sessionID+".json")
+	createdAt := time.Now()
+	if existing, err := readSessionFile(path); err == nil {
+		createdAt = existing.CreatedAt
+	}
+	return writeSessionFile(path, sessionFile{
+		ID:        app.sessionID,
+		Title:     app.sessionTitleText,
+		Model:     app.config.ChatModel,
+		CreatedAt: createdAt,
+		UpdatedAt: time.Now(),
+		Messages:  messages,
+		Tags:      tagsByIndex(records, app.tags),
+		Pins:      app.pinnedIndexes(records),
+		Vars:      app.vars,
+	})
+}
+
+// listSessions prints the saved sessions, most recently updated first.
+func listSessions(config Config) error {
+	dir := sessionsDir(config)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	var sessions []sessionFile
+	for _, path := range paths {
+		session, err := readSessionFile(path)
+		if err != nil {
+			systemColor.Printf("Warning: %v\n", err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	if len(sessions) == 0 {
+		systemColor.Printf("No saved sessions in %s\n", dir)
+		return nil
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	for _, session := range sessions {
+		fmt.Printf("%-16s  %s  %4d msgs  %s\n", session.ID,
+			session.UpdatedAt.Local().Format("2006-01-02 15:04"), len(session.Messages), session.Title)
+	}
+	return nil
+}
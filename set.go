@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// settableOptions lists the options /set can change, with their valid
+// ranges.
+var settableOptions = []struct {
+	key      string
+	min, max float64
+}{
+	{"temperature", 0, 2},
+	{"repeat_penalty", 0, 2},
+	{"repeat_last_n", -1, 32768},
+	{"num_ctx", 512, 1048576},
+	{"top_k", 0, 1000},
+	{"top_p", 0, 1},
+	{"tools_temperature", 0, 2},
+	{"tools_repeat_penalty", 0, 2},
+	{"tools_repeat_last_n", -1, 32768},
+}
+
+func (app *App) setCommand(args []string) {
+	save := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--save" {
+			save = true
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+
+	if len(rest) == 0 {
+		value := reflect.ValueOf(app.config)
+		for _, option := range settableOptions {
+			field, _ := configField(value, option.key)
+			systemColor.Printf("  %-22s %v\n", option.key, field.Interface())
+		}
+		return
+	}
+	if len(rest) != 2 {
+		systemColor.Println("Usage: /set <key> <value> [--save]")
+		return
+	}
+
+	key, text := rest[0], rest[1]
+	if err := app.setOption(key, text); err != nil {
+		systemColor.Printf("Cannot set %s: %v\n", key, err)
+		return
+	}
+	systemColor.Printf("%s set to %s.\n", key, text)
+
+	if save {
+		if err := saveConfigValue("config.yml", key, text); err != nil {
+			systemColor.Printf("Failed to save %s to config.yml: %v\n", key, err)
+			return
+		}
+		systemColor.Println("Saved to config.yml.")
+	}
+}
+
+// setOption parses and range checks text for one of the settableOptions
+// and updates the live config.
+func (app *App) setOption(key, text string) error {
+	for _, option := range settableOptions {
+		if option.key != key {
+			continue
+		}
+		field, ok := configField(reflect.ValueOf(&app.config).Elem(), key)
+		if !ok {
+			return fmt.Errorf("unknown option")
+		}
+
+		var number float64
+		switch field.Kind() {
+		case reflect.Int:
+			value, err := strconv.Atoi(text)
+			if err != nil {
+				return fmt.Errorf("%q is not an integer", text)
+			}
+			number = float64(value)
+		case reflect.Float64:
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", text)
+			}
+			number = value
+		}
+		if number < option.min || number > option.max {
+			return fmt.Errorf("must be between %v and %v", option.min, option.max)
+		}
+
+		if field.Kind() == reflect.Int {
+			field.SetInt(int64(number))
+		} else {
+			field.SetFloat(number)
+		}
+		if app.config.sources == nil {
+			app.config.sources = map[string]string{}
+		}
+		app.config.sources[key] = sourceSet
+		return nil
+	}
+
+	names := make([]string, 0, len(settableOptions))
+	for _, option := range settableOptions {
+		names = append(names, option.key)
+	}
+	return fmt.Errorf("unknown option, expected one of: %s", strings.Join(names, ", "))
+}
+
+// saveConfigValue sets a top level key in a YAML file, editing the line in
+// place so comments and layout are kept.
+func saveConfigValue(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	line := regexp.MustCompile(`(?m)^` + regexp.QuoteMeta(key) + `:.*$`)
+	var updated string
+	if line.Match(data) {
+		updated = line.ReplaceAllLiteralString(string(data), key+": "+value)
+	} else {
+		updated = strings.TrimRight(string(data), "\n") + "\n" + key + ": " + value + "\n"
+	}
+	return os.WriteFile(path, []byte(updated), 0644)
+}
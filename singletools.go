@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/llm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runSingleModelTools lets one tool-capable model both decide on tool calls
+// and answer, through the chat API. Tool results are fed back until the
+// model replies with content; after agent_max_steps rounds the tools are
+// withheld so it has to answer. Calls to unknown tools get an error result.
+// The answer is not streamed.
+func (app *App) runSingleModelTools(ctx context.Context, history []llm.Message, model string, options llm.Options) ([]llm.Message, string, error) {
+	for step := 0; ; step++ {
+		query := llm.Query{
+			Model:    model,
+			Messages: app.buildMessages(history),
+			Tools:    app.ollamaTools,
+			Options:  options,
+		}
+		if step >= app.config.AgentMaxSteps {
+			query.Tools = nil
+		}
+
+		_, span := tracer.Start(ctx, "chat_with_tools", trace.WithAttributes(attribute.String("llm.model", model)))
+		answer, err := completion.Chat(app.config.apiURL(), query)
+		app.recordExchange(query, answer)
+		answer.Message.Role = app.config.RoleMap.incoming(answer.Message.Role)
+		app.addUsage(model, answer)
+		if err == nil {
+			span.SetAttributes(
+				attribute.Int("llm.prompt_tokens", answer.PromptEvalCount),
+				attribute.Int("llm.completion_tokens", answer.EvalCount),
+			)
+		}
+		endSpan(span, err)
+		observeCompletion("chat", answer, err)
+		if err != nil {
+			return history, "", fmt.Errorf("failed to get response from LLM: %w", err)
+		}
+
+		if len(answer.Message.ToolCalls) == 0 {
+			app.lastAnswer = answer
+			label := "LLoms: "
+			if model != app.config.ChatModel {
+				label = fmt.Sprintf("LLoms (%s): ", model)
+			}
+			// Every sink gets the same redacted text, as with a streamed answer.
+			shown := newRedactor(app.config.Redact).redact(answer.Message.Content)
+			assistantColor.Print(label)
+			fmt.Fprintln(app.out, shown)
+			app.teePrintf("%s%s\n", label, shown)
+			return history, answer.Message.Content, nil
+		}
+
+		if step > 0 {
+			systemColor.Printf("%s Tool step %d/%d\n", iconAgent, step, app.config.AgentMaxSteps)
+		}
+		for _, toolCall := range answer.Message.ToolCalls {
+			// A call to a tool that does not exist gets an error result, so
+			// the model does not ask the same thing again.
+			if _, found := findSimilarTool(toolCall.Function.Name, app.ollamaTools); !found {
+				systemColor.Printf("Warning: Tool call to unknown tool '%s'.\n", toolCall.Function.Name)
+				history = app.recordToolResult(history, toolCall.Function.Name, fmt.Sprintf("Error: there is no tool named %s", toolCall.Function.Name))
+				continue
+			}
+			history, err = app.executeToolCall(ctx, history, toolCall)
+			if err != nil {
+				return history, "", err
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+// teeWriter writes to the --tee file through teePrintf, which turns the
+// file off after a failed write instead of failing the stream.
+type teeWriter struct {
+	app *App
+}
+
+func (w teeWriter) Write(p []byte) (int, error) {
+	w.app.teePrintf("%s", p)
+	return len(p), nil
+}
+
+// streamSinks returns the writer a chat answer is streamed to, built from
+// the outputs enabled for this answer: the output, paced by --type-delay,
+// or pagerBuffer when the answer is shown through the pager, and the --tee
+// file. Every sink gets the same redacted text; new outputs for the stream
+// are added here. The returned function must be called once the answer is
+// complete; it waits for --type-delay to finish displaying it, or shows the
+// rest at once when the answer was interrupted or an interrupt arrives.
+func (app *App) streamSinks(pagerBuffer *strings.Builder, interrupts <-chan os.Signal) (io.Writer, func(interrupted bool)) {
+	var sinks []io.Writer
+	if app.tee != nil {
+		sinks = append(sinks, teeWriter{app})
+	}
+	done := func(bool) {}
+	switch {
+	case pagerBuffer != nil:
+		sinks = append(sinks, pagerBuffer)
+	case app.typeDelay > 0 && app.out == os.Stdout && isatty.IsTerminal(os.Stdout.Fd()):
+		typing := newTypeWriter(app.out, app.typeDelay)
+		sinks = append(sinks, typing)
+		done = func(interrupted bool) {
+			if interrupted {
+				typing.Flush()
+			}
+			typing.Close(interrupts)
+		}
+	default:
+		sinks = append(sinks, app.out)
+	}
+	return io.MultiWriter(sinks...), done
+}
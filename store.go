@@ -0,0 +1,208 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/parakeet-nest/parakeet/history"
+	"github.com/parakeet-nest/parakeet/llm"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	StorageMemory = "memory"
+	StorageSQLite = "sqlite"
+)
+
+// HistoryStore holds the messages of the current session. Records are
+// returned ordered by id, which generateMsgID keeps chronological.
+type HistoryStore interface {
+	Save(id string, message llm.Message) error
+	GetAll() ([]llm.MessageRecord, error)
+	Delete(id string) error
+	Clear() error
+	Close() error
+}
+
+func openHistoryStore(config Config, sessionID string) (HistoryStore, error) {
+	switch config.StorageBackend {
+	case "", StorageMemory:
+		return newMemoryStore(), nil
+	case StorageSQLite:
+		return openSQLiteStore(config.StoragePath, sessionID)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", config.StorageBackend)
+	}
+}
+
+// newSessionID returns an ID that sorts by creation time. The microseconds
+// keep sessions started within the same second, e.g. by /reset or
+// /detach, apart.
+func newSessionID() string {
+	now := time.Now()
+	return fmt.Sprintf("%s-%06d", now.Format("20060102-150405"), now.Nanosecond()/int(time.Microsecond))
+}
+
+func defaultStoragePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".lloms", "lloms.db")
+	}
+	return filepath.Join(home, ".lloms", "lloms.db")
+}
+
+func messagesOf(records []llm.MessageRecord) []llm.Message {
+	messages := make([]llm.Message, 0, len(records))
+	for _, record := range records {
+		messages = append(messages, llm.Message{Role: record.Role, Content: record.Content})
+	}
+	return messages
+}
+
+// memoryStore guards the parakeet map store, which is not safe for
+// concurrent use.
+type memoryStore struct {
+	mu       sync.Mutex
+	messages history.MemoryMessages
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		messages: history.MemoryMessages{
+			Messages: make(map[string]llm.MessageRecord),
+		},
+	}
+}
+
+func (m *memoryStore) Save(id string, message llm.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.messages.SaveMessage(id, message)
+	return err
+}
+
+func (m *memoryStore) GetAll() ([]llm.MessageRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	records, err := m.messages.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+	return records, nil
+}
+
+func (m *memoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages.RemoveMessage(id)
+}
+
+func (m *memoryStore) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages.RemoveAllMessages()
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
+
+type sqliteStore struct {
+	db        *sql.DB
+	sessionID string
+}
+
+func openSQLiteStore(path, sessionID string) (*sqliteStore, error) {
+	if path == "" {
+		path = defaultStoragePath()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS messages (
+		session_id TEXT NOT NULL,
+		id         TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		PRIMARY KEY (session_id, id)
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create messages table: %w", err)
+	}
+	return &sqliteStore{db: db, sessionID: sessionID}, nil
+}
+
+func (s *sqliteStore) Save(id string, message llm.Message) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO messages (session_id, id, role, content) VALUES (?, ?, ?, ?)`,
+		s.sessionID, id, message.Role, message.Content)
+	return err
+}
+
+func (s *sqliteStore) GetAll() ([]llm.MessageRecord, error) {
+	rows, err := s.db.Query(`SELECT id, role, content FROM messages WHERE session_id = ? ORDER BY id`, s.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []llm.MessageRecord
+	for rows.Next() {
+		record := llm.MessageRecord{SessionId: s.sessionID}
+		if err := rows.Scan(&record.Id, &record.Role, &record.Content); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (s *sqliteStore) Delete(id string) error {
+	_, err := s.db.Exec(`DELETE FROM messages WHERE session_id = ? AND id = ?`, s.sessionID, id)
+	return err
+}
+
+func (s *sqliteStore) Clear() error {
+	_, err := s.db.Exec(`DELETE FROM messages WHERE session_id = ?`, s.sessionID)
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// searchAll finds the messages of every stored session containing query,
+// ignoring case, with their position in their session.
+func (s *sqliteStore) searchAll(query string) ([]searchHit, error) {
+	rows, err := s.db.Query(`SELECT session_id, position, role, content FROM (
+		SELECT session_id, role, content,
+			ROW_NUMBER() OVER (PARTITION BY session_id ORDER BY id) - 1 AS position
+		FROM messages
+	) WHERE instr(lower(content), lower(?)) > 0
+	ORDER BY session_id, position`, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []searchHit
+	for rows.Next() {
+		var hit searchHit
+		if err := rows.Scan(&hit.sessionID, &hit.index, &hit.role, &hit.content); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
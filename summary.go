@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/enums/option"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const defaultToolSummaryPrompt = "Summarize the following tool output. Keep every fact, number, name and link that could answer the user's request and drop boilerplate, markup and navigation text. Reply with the summary only."
+
+// ToolSummaryConfig selects tools whose results are summarized by a small
+// model before they are added to the conversation.
+type ToolSummaryConfig struct {
+	Tools  []string `yaml:"tools"`
+	Model  string   `yaml:"model"`
+	Prompt string   `yaml:"prompt"`
+}
+
+// summarizeToolResult returns a summary of result when the tool is listed
+// in tool_summary.tools. The model defaults to the tools model.
+func (app *App) summarizeToolResult(toolName, result string) (string, bool) {
+	summary := app.config.ToolSummary
+	if !matchesToolPattern(toolName, summary.Tools) {
+		return "", false
+	}
+	model := summary.Model
+	if model == "" {
+		model = app.config.ToolsModel
+	}
+	prompt := summary.Prompt
+	if prompt == "" {
+		prompt = defaultToolSummaryPrompt
+	}
+
+	systemColor.Printf("Summarizing %s result with %s...\n", toolName, model)
+	answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+		Model: model,
+		Messages: app.config.RoleMap.outgoing([]llm.Message{
+			{Role: RoleSystem, Content: prompt},
+			{Role: RoleUser, Content: result},
+		}),
+		Options: llm.SetOptions(map[string]any{
+			option.Temperature: 0.0,
+			option.NumCtx:      app.config.NumCtx,
+		}),
+	})
+	if err != nil {
+		systemColor.Printf("Warning: Failed to summarize tool result, using it as is: %v\n", err)
+		return "", false
+	}
+	text := strings.TrimSpace(answer.Message.Content)
+	if text == "" {
+		return "", false
+	}
+	return text, true
+}
+
+const defaultConversationSummaryPrompt = "Summarize the following conversation for someone who has to pick it up. List the questions asked, the answers and decisions reached, the facts found with tools and anything still open. Be concise and reply with the summary only."
+
+// ConversationSummary configures /summarize.
+type ConversationSummary struct {
+	Model  string `yaml:"model"`
+	Prompt string `yaml:"prompt"`
+}
+
+// summarizeConversation prints a summary of the whole conversation, and
+// writes it to path when one is given. The conversation is not changed.
+// The model defaults to the chat model.
+func (app *App) summarizeConversation(path string) {
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	var transcript strings.Builder
+	for _, record := range records {
+		if record.Role == RoleSystem || strings.TrimSpace(record.Content) == "" {
+			continue
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", record.Role, record.Content)
+	}
+	if transcript.Len() == 0 {
+		systemColor.Println("There is nothing to summarize yet.")
+		return
+	}
+	model := app.config.ConversationSummary.Model
+	if model == "" {
+		model = app.config.ChatModel
+	}
+	prompt := app.config.ConversationSummary.Prompt
+	if prompt == "" {
+		prompt = defaultConversationSummaryPrompt
+	}
+
+	systemColor.Printf("Summarizing the conversation with %s...\n", model)
+	answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+		Model: model,
+		Messages: app.config.RoleMap.outgoing([]llm.Message{
+			{Role: RoleSystem, Content: prompt},
+			{Role: RoleUser, Content: transcript.String()},
+		}),
+		Options: llm.SetOptions(map[string]any{
+			option.Temperature: 0.0,
+			option.NumCtx:      app.config.NumCtx,
+		}),
+	})
+	app.addUsage(model, answer)
+	if err != nil {
+		systemColor.Printf("Failed to summarize the conversation: %v\n", err)
+		return
+	}
+	// Reasoning models think before answering; only the answer is kept.
+	var thinking thinkStream
+	_, text := thinking.write(answer.Message.Content)
+	_, rest := thinking.flush()
+	summary := newRedactor(app.config.Redact).redact(strings.TrimSpace(text + rest))
+	assistantColor.Println(summary)
+	if path == "" {
+		return
+	}
+	if err := os.WriteFile(path, []byte(summary+"\n"), 0644); err != nil {
+		systemColor.Printf("Failed to write %s: %v\n", path, err)
+		return
+	}
+	systemColor.Printf("Summary saved to %s.\n", path)
+}
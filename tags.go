@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// tagCommand tags the most recent message of the conversation.
+func (app *App) tagCommand(tag string) {
+	if tag == "" {
+		systemColor.Println("Usage: /tag <text>")
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil || len(records) == 0 {
+		systemColor.Println("There is no message to tag.")
+		return
+	}
+	id := records[len(records)-1].Id
+	if app.tags == nil {
+		app.tags = map[string][]string{}
+	}
+	if !slices.Contains(app.tags[id], tag) {
+		app.tags[id] = append(app.tags[id], tag)
+	}
+	systemColor.Printf("Tagged message %d with %q.\n", len(records)-1, tag)
+}
+
+// findTagged lists the messages carrying a tag, matched without regard to
+// case.
+func (app *App) findTagged(tag string) {
+	if tag == "" {
+		systemColor.Println("Usage: /find <tag>")
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	found := 0
+	for i, record := range records {
+		if !slices.ContainsFunc(app.tags[record.Id], func(t string) bool { return strings.EqualFold(t, tag) }) {
+			continue
+		}
+		found++
+		systemColor.Printf("[%d] %s [%s]: ", i, record.Role, strings.Join(app.tags[record.Id], ", "))
+		fmt.Fprintln(app.out, messagePreview(record.Content))
+	}
+	if found == 0 {
+		systemColor.Printf("No messages tagged %q.\n", tag)
+	}
+}
+
+// messagePreview returns the first line of content, shortened for listings.
+func messagePreview(content string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(content), "\n")
+	runes := []rune(line)
+	if len(runes) > 100 {
+		return string(runes[:99]) + "…"
+	}
+	return line
+}
+
+// tagsByIndex converts the tags of records to a map keyed by message
+// position, as stored in session files.
+func tagsByIndex(records []llm.MessageRecord, tags map[string][]string) map[int][]string {
+	indexed := map[int][]string{}
+	for i, record := range records {
+		if recordTags, ok := tags[record.Id]; ok {
+			indexed[i] = recordTags
+		}
+	}
+	return indexed
+}
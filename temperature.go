@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	minTemperature = 0.0
+	maxTemperature = 2.0
+)
+
+// TemperatureSchedule varies the chat temperature across turns. Values
+// gives an explicit temperature per turn (the last one repeats), otherwise
+// Decay multiplies the base temperature once per turn.
+type TemperatureSchedule struct {
+	Values []float64 `yaml:"values"`
+	Decay  float64   `yaml:"decay"`
+}
+
+func (s TemperatureSchedule) temperatureFor(base float64, turn int) float64 {
+	temperature := base
+	switch {
+	case len(s.Values) > 0:
+		temperature = s.Values[min(turn, len(s.Values)-1)]
+	case s.Decay > 0:
+		temperature = base * math.Pow(s.Decay, float64(turn))
+	}
+	return clampTemperature(temperature)
+}
+
+func (s TemperatureSchedule) String() string {
+	switch {
+	case len(s.Values) > 0:
+		values := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			values[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return strings.Join(values, ",")
+	case s.Decay > 0:
+		return fmt.Sprintf("decay:%g", s.Decay)
+	}
+	return "off"
+}
+
+func clampTemperature(temperature float64) float64 {
+	return math.Max(minTemperature, math.Min(maxTemperature, temperature))
+}
+
+// parseTemperatureSchedule parses a /temp ramp spec: either a comma
+// separated list of per-turn values ("0.9,0.7,0.4"), "decay:<factor>" or
+// "off".
+func parseTemperatureSchedule(spec string) (TemperatureSchedule, error) {
+	if spec == "off" {
+		return TemperatureSchedule{}, nil
+	}
+
+	if factor, ok := strings.CutPrefix(spec, "decay:"); ok {
+		decay, err := strconv.ParseFloat(factor, 64)
+		if err != nil || decay <= 0 {
+			return TemperatureSchedule{}, fmt.Errorf("invalid decay factor %q", factor)
+		}
+		return TemperatureSchedule{Decay: decay}, nil
+	}
+
+	var schedule TemperatureSchedule
+	for _, part := range strings.Split(spec, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return TemperatureSchedule{}, fmt.Errorf("invalid temperature %q", part)
+		}
+		schedule.Values = append(schedule.Values, clampTemperature(value))
+	}
+	return schedule, nil
+}
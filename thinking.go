@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// thinkStream splits the streamed answer of a reasoning model into its
+// <think> section and the answer itself. A chunk ending in what may be the
+// start of a tag is held back until the next one arrives.
+type thinkStream struct {
+	inside  bool
+	pending string
+	// started is set once the answer has shown something other than
+	// the blank lines that follow the thinking.
+	started bool
+}
+
+// write adds a chunk and returns the thinking and answer text it completes.
+func (s *thinkStream) write(chunk string) (thinking, answer string) {
+	s.pending += chunk
+	var think, out strings.Builder
+	for {
+		tag := thinkOpenTag
+		if s.inside {
+			tag = thinkCloseTag
+		}
+		end := strings.Index(s.pending, tag)
+		next := end + len(tag)
+		if end < 0 {
+			end = len(s.pending) - partialTag(s.pending, tag)
+			next = end
+		}
+		if s.inside {
+			think.WriteString(s.pending[:end])
+		} else {
+			out.WriteString(s.pending[:end])
+		}
+		if next == end {
+			s.pending = s.pending[end:]
+			return think.String(), s.answer(out.String())
+		}
+		s.pending = s.pending[next:]
+		s.inside = !s.inside
+	}
+}
+
+// flush returns the text still held back.
+func (s *thinkStream) flush() (thinking, answer string) {
+	rest := s.pending
+	s.pending = ""
+	if s.inside {
+		return rest, ""
+	}
+	return "", s.answer(rest)
+}
+
+func (s *thinkStream) answer(text string) string {
+	if !s.started {
+		text = strings.TrimLeft(text, "\r\n")
+		s.started = text != ""
+	}
+	return text
+}
+
+// partialTag returns the length of the longest suffix of text that is a
+// prefix of tag.
+func partialTag(text, tag string) int {
+	for n := min(len(tag)-1, len(text)); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// writeThinking appends the thinking of the turn that just ended to the
+// --thinking-file.
+func (app *App) writeThinking(model string) {
+	thinking := strings.TrimSpace(app.turnThinking.String())
+	if app.thinkingFile == nil || thinking == "" {
+		return
+	}
+	_, err := fmt.Fprintf(app.thinkingFile, "--- turn %d, %s, %s ---\n%s\n\n", app.turn, model, time.Now().Format(time.RFC3339), thinking)
+	if err != nil {
+		systemColor.Printf("Warning: Failed to write thinking file, disabling it: %v\n", err)
+		app.thinkingFile.Close()
+		app.thinkingFile = nil
+	}
+}
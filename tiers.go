@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ModelTier selects a different chat model once the conversation crosses
+// any of its thresholds. Zero values disable a threshold.
+type ModelTier struct {
+	Model       string   `yaml:"model"`
+	MinMessages int      `yaml:"min_messages"`
+	MinTokens   int      `yaml:"min_tokens"`
+	Keywords    []string `yaml:"keywords"`
+}
+
+func validateModelTiers(tiers []ModelTier) error {
+	for i, tier := range tiers {
+		if tier.Model == "" {
+			return fmt.Errorf("model_tiers[%d]: model is required", i)
+		}
+		if tier.MinMessages <= 0 && tier.MinTokens <= 0 && len(tier.Keywords) == 0 {
+			return fmt.Errorf("model_tiers[%d]: set at least one of min_messages, min_tokens or keywords", i)
+		}
+	}
+	return nil
+}
+
+func (tier ModelTier) matches(messages, tokens int, input string) bool {
+	if tier.MinMessages > 0 && messages >= tier.MinMessages {
+		return true
+	}
+	if tier.MinTokens > 0 && tokens >= tier.MinTokens {
+		return true
+	}
+	input = strings.ToLower(input)
+	for _, keyword := range tier.Keywords {
+		if strings.Contains(input, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// chatModelFor picks the chat model for a turn: the first model tier that
+// matches the conversation so far, or chat_model. The token count is the
+// prompt size reported for the previous answer.
+func (app *App) chatModelFor(input string) string {
+	model := app.config.ChatModel
+	if len(app.config.ModelTiers) > 0 {
+		messages := 0
+		if records, err := app.conversation.GetAll(); err == nil {
+			messages = len(records)
+		}
+		for _, tier := range app.config.ModelTiers {
+			if tier.matches(messages, app.lastAnswer.PromptEvalCount, input) {
+				model = tier.Model
+				break
+			}
+		}
+	}
+
+	if app.activeModel != "" && model != app.activeModel {
+		systemColor.Printf("Switching chat model from %s to %s\n", app.activeModel, model)
+	}
+	app.activeModel = model
+	return model
+}
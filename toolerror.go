@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	ToolErrorContinue = "continue"
+	ToolErrorAbort    = "abort"
+	ToolErrorAsk      = "ask"
+)
+
+// errTurnAborted ends a turn early without ending the session.
+var errTurnAborted = errors.New("turn aborted")
+
+// handleToolError applies the tool_error_policy to a failed tool call. It
+// returns the text to feed to the model as the tool result, or an error
+// wrapping errTurnAborted.
+func (app *App) handleToolError(toolName string, err error) (string, error) {
+	systemColor.Printf("Tool call failed: %v\n", err)
+
+	abort := false
+	switch app.config.ToolErrorPolicy {
+	case ToolErrorAbort:
+		abort = true
+	case ToolErrorAsk:
+		abort = !confirm(fmt.Sprintf("Tool %s failed. Continue the turn?", toolName))
+	}
+	if abort {
+		return "", fmt.Errorf("%w: tool %s failed: %v", errTurnAborted, toolName, err)
+	}
+	return fmt.Sprintf("Error: %v", err), nil
+}
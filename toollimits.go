@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+)
+
+// ToolLimit overrides tool_call_timeout and tool_call_retries for one tool.
+// Unset fields fall back to the global values.
+type ToolLimit struct {
+	Timeout string `yaml:"timeout"`
+	Retries *int   `yaml:"retries"`
+}
+
+func validateToolTimeout(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	if timeout, err := time.ParseDuration(value); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid %s %q: expected a positive duration such as 30s", key, value)
+	}
+	return nil
+}
+
+func validateToolLimits(config Config) error {
+	if err := validateToolTimeout("tool_call_timeout", config.ToolCallTimeout); err != nil {
+		return err
+	}
+	if config.ToolCallRetries < 0 {
+		return fmt.Errorf("invalid tool_call_retries %d: must not be negative", config.ToolCallRetries)
+	}
+	for name, limit := range config.ToolLimits {
+		if err := validateToolTimeout(fmt.Sprintf("tool_limits.%s.timeout", name), limit.Timeout); err != nil {
+			return err
+		}
+		if limit.Retries != nil && *limit.Retries < 0 {
+			return fmt.Errorf("invalid tool_limits.%s.retries %d: must not be negative", name, *limit.Retries)
+		}
+	}
+	return nil
+}
+
+// toolLimit returns the timeout (0 for none) and number of retries of a
+// tool call, layering its tool_limits entry over the global values.
+func (config Config) toolLimit(name string) (time.Duration, int) {
+	timeout, retries := config.ToolCallTimeout, config.ToolCallRetries
+	if limit, ok := config.ToolLimits[name]; ok {
+		if limit.Timeout != "" {
+			timeout = limit.Timeout
+		}
+		if limit.Retries != nil {
+			retries = *limit.Retries
+		}
+	}
+	duration, _ := time.ParseDuration(timeout)
+	return duration, retries
+}
+
+// callMCPTool calls a tool on its MCP server, retrying calls that time out
+// or do not reach the server as configured. An error returned by the tool
+// itself is not retried.
+func (app *App) callMCPTool(name string, arguments map[string]any) (result mcpstdio.CallToolResult, err error) {
+	timeout, retries := app.config.toolLimit(name)
+	for attempt := 0; ; attempt++ {
+		result, err = app.mcp.callTool(name, arguments, timeout)
+		if err == nil || !retryable(err) || attempt == retries {
+			return result, err
+		}
+		systemColor.Printf("Tool %s failed: %v. Retrying (%d/%d)...\n", name, err, attempt+1, retries)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultToolCallNotePattern matches the <tool_call> blocks of
+// defaultInlineToolCallPattern and bare {"name": ..., "arguments": {...}}
+// objects, the two shapes tool-trained models fall back to without tools.
+const defaultToolCallNotePattern = `(?s)<tool_call>\s*(\{.*?\})\s*</tool_call>|(\{\s*"name"\s*:\s*"[^"]*"\s*,\s*"arguments"\s*:\s*\{.*?\}\s*\})`
+
+// toolCallHoldBack bounds how much text toolNoteStream holds back waiting
+// for a possible tool call to complete.
+const toolCallHoldBack = 2048
+
+// toolNoteStream replaces the tool calls a model writes when no tools are
+// loaded with a short note, for tool_call_notes.
+type toolNoteStream struct {
+	pattern *regexp.Regexp
+	// prog is pattern compiled for couldMatch.
+	prog    *syntax.Prog
+	pending string
+}
+
+// newToolNoteStream returns a stream replacing the calls matched by
+// pattern, which must be a valid regular expression.
+func newToolNoteStream(pattern string) toolNoteStream {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		panic(err)
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		panic(err)
+	}
+	return toolNoteStream{pattern: regexp.MustCompile(pattern), prog: prog}
+}
+
+// write adds a chunk and returns the text that is safe to show.
+func (s *toolNoteStream) write(chunk string) string {
+	if s.pattern == nil {
+		return chunk
+	}
+	s.pending += chunk
+	var out strings.Builder
+	for {
+		start := s.callStart(s.pending)
+		if start < 0 {
+			out.WriteString(s.pending)
+			s.pending = ""
+			break
+		}
+		out.WriteString(s.pending[:start])
+		s.pending = s.pending[start:]
+		// Only a call starting right here counts: one further on may be
+		// the inside of a call that is not complete yet.
+		if loc := s.pattern.FindStringSubmatchIndex(s.pending); loc != nil && loc[0] == 0 && loc[1] > 0 {
+			if call, ok := parseInlineToolCall(toolCallNotePayload(s.pending, loc)); ok {
+				out.WriteString(toolCallNote(call))
+				s.pending = s.pending[loc[1]:]
+				continue
+			}
+		}
+		if len(s.pending) <= toolCallHoldBack {
+			break
+		}
+		// Too long to be a call still being written: let the first
+		// character through and look again after it.
+		_, size := utf8.DecodeRuneInString(s.pending)
+		out.WriteString(s.pending[:size])
+		s.pending = s.pending[size:]
+	}
+	return out.String()
+}
+
+// flush returns the text still held back, with any complete calls
+// replaced.
+func (s *toolNoteStream) flush() string {
+	text := s.pending
+	s.pending = ""
+	if s.pattern == nil {
+		return text
+	}
+	var out strings.Builder
+	for text != "" {
+		loc := s.pattern.FindStringSubmatchIndex(text)
+		if loc == nil || loc[0] == loc[1] {
+			out.WriteString(text)
+			break
+		}
+		out.WriteString(text[:loc[0]])
+		if call, ok := parseInlineToolCall(toolCallNotePayload(text, loc)); ok {
+			out.WriteString(toolCallNote(call))
+		} else {
+			out.WriteString(text[loc[0]:loc[1]])
+		}
+		text = text[loc[1]:]
+	}
+	return out.String()
+}
+
+// callStart returns the offset of the first position in text from which
+// the pattern matches, or could still match once more text arrives, or -1
+// when there is none.
+func (s *toolNoteStream) callStart(text string) int {
+	prev := rune(-1)
+	for i, r := range text {
+		if couldMatch(s.prog, text[i:], prev) {
+			return i
+		}
+		prev = r
+	}
+	return -1
+}
+
+// couldMatch reports whether prog matches a non-empty prefix of text, or
+// whether text is the start of a match that more text could complete. prev
+// is the rune before text, or -1. It runs prog as an NFA over text and
+// looks for threads still alive at its end.
+func couldMatch(prog *syntax.Prog, text string, prev rune) bool {
+	// At the end of text the next rune is not known yet, so any empty-width
+	// assertion may still hold there.
+	context := func(offset int, before rune) syntax.EmptyOp {
+		if offset >= len(text) {
+			return ^syntax.EmptyOp(0)
+		}
+		after, _ := utf8.DecodeRuneInString(text[offset:])
+		return syntax.EmptyOpContext(before, after)
+	}
+	threads := followThreads(prog, nil, make([]bool, len(prog.Inst)), uint32(prog.Start), context(0, prev))
+	offset := 0
+	for _, r := range text {
+		offset += utf8.RuneLen(r)
+		var next []uint32
+		seen := make([]bool, len(prog.Inst))
+		for _, pc := range threads {
+			inst := &prog.Inst[pc]
+			if matchesRune(inst, r) {
+				next = followThreads(prog, next, seen, inst.Out, context(offset, r))
+			}
+		}
+		if len(next) == 0 {
+			return false
+		}
+		for _, pc := range next {
+			if prog.Inst[pc].Op == syntax.InstMatch {
+				return true
+			}
+		}
+		threads = next
+	}
+	return true
+}
+
+// matchesRune reports whether inst consumes r.
+func matchesRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRune, syntax.InstRune1:
+		return inst.MatchRune(r)
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	}
+	return false
+}
+
+// followThreads adds to threads the instructions of prog reachable from pc
+// without consuming a rune, given the empty-width assertions that hold.
+func followThreads(prog *syntax.Prog, threads []uint32, seen []bool, pc uint32, context syntax.EmptyOp) []uint32 {
+	if seen[pc] {
+		return threads
+	}
+	seen[pc] = true
+	inst := &prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		threads = followThreads(prog, threads, seen, inst.Out, context)
+		return followThreads(prog, threads, seen, inst.Arg, context)
+	case syntax.InstCapture, syntax.InstNop:
+		return followThreads(prog, threads, seen, inst.Out, context)
+	case syntax.InstEmptyWidth:
+		if syntax.EmptyOp(inst.Arg)&^context != 0 {
+			return threads
+		}
+		return followThreads(prog, threads, seen, inst.Out, context)
+	case syntax.InstFail:
+		return threads
+	}
+	return append(threads, pc)
+}
+
+// toolCallNotePayload returns the first capture group of the match at loc
+// that matched, or the whole match when none did, so each alternative of
+// tool_call_note_pattern can capture its own call.
+func toolCallNotePayload(text string, loc []int) string {
+	for group := 2; group+1 < len(loc); group += 2 {
+		if loc[group] >= 0 {
+			return text[loc[group]:loc[group+1]]
+		}
+	}
+	return text[loc[0]:loc[1]]
+}
+
+func toolCallNote(call inlineToolCall) string {
+	arguments, err := json.Marshal(call.Arguments)
+	if err != nil || len(call.Arguments) == 0 {
+		return fmt.Sprintf("[The assistant wanted to call %s, but no tools are available.]", call.Name)
+	}
+	return fmt.Sprintf("[The assistant wanted to call %s with %s, but no tools are available.]", call.Name, arguments)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// proseProbeBytes is how much of the tools model's text is looked at
+// before deciding it is answering in prose.
+const proseProbeBytes = 16
+
+// errProseAnswer stops the tools query once it is clearly not calling a
+// tool.
+var errProseAnswer = errors.New("tools model answered in prose")
+
+// streamToolsQuery runs the tools query as a stream for tools_early_abort.
+// It reports true, with no answer, when the tools model starts writing
+// prose, so the turn can go straight to the chat model instead of waiting
+// for the rest of it.
+func (app *App) streamToolsQuery(query llm.Query) (llm.Answer, bool, error) {
+	var content strings.Builder
+	var toolCalls []llm.ToolCall
+	var final llm.Answer
+	_, err := completion.ChatStream(app.config.apiURL(), query,
+		func(answer llm.Answer) error {
+			content.WriteString(answer.Message.Content)
+			// The chunks are decoded into the same Answer, so a chunk
+			// without tool calls still carries those of the one before.
+			if len(answer.Message.ToolCalls) > 0 {
+				toolCalls = answer.Message.ToolCalls
+			}
+			if answer.Done {
+				final = answer
+				return nil
+			}
+			if len(toolCalls) == 0 && looksLikeProse(content.String()) {
+				return errProseAnswer
+			}
+			return nil
+		},
+	)
+	if errors.Is(err, errProseAnswer) {
+		partial := llm.Answer{Model: query.Model}
+		partial.Message.Content = content.String()
+		app.recordExchange(query, partial)
+		return llm.Answer{}, true, nil
+	}
+	if err != nil {
+		return llm.Answer{}, false, err
+	}
+	final.Message.Content = content.String()
+	final.Message.ToolCalls = toolCalls
+	return final, false, nil
+}
+
+// looksLikeProse guesses from the start of a response whether it is plain
+// text rather than a tool call. Tool calls come as structured tool_calls
+// or as JSON or markup in the text, none of which starts with a word. The
+// thinking of reasoning models is skipped.
+func looksLikeProse(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, thinkOpenTag) {
+		_, after, closed := strings.Cut(text, thinkCloseTag)
+		if !closed {
+			return false
+		}
+		text = strings.TrimSpace(after)
+	}
+	if len(text) < proseProbeBytes {
+		return false
+	}
+	first := []rune(text)[0]
+	return unicode.IsLetter(first) && strings.ContainsAny(text, " \n")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+// staticTool is a tool definition from tools_file. Calls to it return
+// Result instead of reaching a server.
+type staticTool struct {
+	llm.Tool
+	Result string `json:"result"`
+}
+
+// loadToolsFile reads a JSON array of tool definitions, each an Ollama tool
+// with an optional "result" returned when the tool is called.
+func loadToolsFile(path string) ([]staticTool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tools []staticTool
+	if err := json.Unmarshal(data, &tools); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i, tool := range tools {
+		if tool.Function.Name == "" {
+			return nil, fmt.Errorf("%s: tool %d has no function name", path, i)
+		}
+		if tool.Type == "" {
+			tools[i].Type = "function"
+		}
+	}
+	return tools, nil
+}
+
+// initStaticTools loads tools_file, if set, and offers its tools to the
+// model.
+func (app *App) initStaticTools() {
+	app.staticTools = nil
+	if app.config.ToolsFile == "" {
+		return
+	}
+	tools, err := loadToolsFile(app.config.ToolsFile)
+	if err != nil {
+		systemColor.Printf("Warning: Failed to load tools_file: %v\n", err)
+		return
+	}
+	app.staticTools = make(map[string]string, len(tools))
+	toolColor.Printf("[%s] static tools loaded:\n", app.config.ToolsFile)
+	for _, tool := range tools {
+		app.staticTools[tool.Function.Name] = tool.Result
+		app.ollamaTools = append(app.ollamaTools, tool.Tool)
+		toolColor.Printf("  %d. %s\n", len(app.ollamaTools), tool.Function.Name)
+	}
+}
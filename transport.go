@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// version is the lloms version sent in the default user agent. Release
+// builds set it with -ldflags "-X main.version=<version>".
+var version = "dev"
+
+// baseTransport is the transport in place before lloms customised it, so
+// that reloading the config starts again from a clean slate.
+var baseTransport = http.DefaultTransport.(*http.Transport)
+
+// chatStream holds the context of the chat answer being streamed, if any.
+// parakeet sends its requests without a context, so this is how streamChat
+// cancels one on Ctrl-C, even before the first chunk has arrived.
+var chatStream struct {
+	sync.Mutex
+	ctx context.Context
+}
+
+// setChatStreamContext binds the chat requests sent from now on to ctx, or
+// to none when ctx is nil.
+func setChatStreamContext(ctx context.Context) {
+	chatStream.Lock()
+	defer chatStream.Unlock()
+	chatStream.ctx = ctx
+}
+
+func chatStreamContext() context.Context {
+	chatStream.Lock()
+	defer chatStream.Unlock()
+	return chatStream.ctx
+}
+
+// headerTransport sets the user agent of every request and adds the
+// configured headers to requests sent to the Ollama hosts. It also adds
+// keep_alive to chat requests, which parakeet can only send as a boolean.
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	hosts     map[string]bool
+	headers   map[string]string
+	keepAlive any
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if streamCtx := chatStreamContext(); streamCtx != nil && strings.HasSuffix(req.URL.Path, "/api/chat") {
+		ctx = streamCtx
+	}
+	req = req.Clone(ctx)
+	req.Header.Set("User-Agent", t.userAgent)
+	if t.hosts[req.URL.Host] {
+		for name, value := range t.headers {
+			req.Header.Set(name, value)
+		}
+		if t.keepAlive != nil && req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/api/chat") {
+			if err := setKeepAlive(req, t.keepAlive); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// setKeepAlive adds keep_alive to the JSON body of req, unless it is
+// already there.
+func setKeepAlive(req *http.Request, keepAlive any) error {
+	if req.Body == nil {
+		return nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil {
+		if _, ok := fields["keep_alive"]; !ok {
+			if fields["keep_alive"], err = json.Marshal(keepAlive); err != nil {
+				return err
+			}
+			if data, err = json.Marshal(fields); err != nil {
+				return err
+			}
+		}
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return nil
+}
+
+// configureHTTPTransport applies the proxy, CA, user agent, header and
+// keep_alive settings to the default HTTP transport. parakeet builds a plain
+// http.Client for every completion call, so the default transport is the
+// only place these can be injected.
+func configureHTTPTransport(config Config) error {
+	transport := baseTransport.Clone()
+
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return fmt.Errorf("invalid http_proxy %q: %w", config.HTTPProxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return fmt.Errorf("failed to read ca_cert_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no valid certificates found in %s", config.CACertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	hosts, err := ollamaHosts(config)
+	if err != nil {
+		return err
+	}
+	withHeaders := &headerTransport{base: transport, userAgent: config.UserAgent, hosts: map[string]bool{}}
+	if config.KeepAlive != "" {
+		withHeaders.keepAlive = keepAliveValue(config.KeepAlive)
+	}
+	for _, host := range hosts {
+		withHeaders.hosts[host.Host] = true
+	}
+	withHeaders.headers = make(map[string]string, len(config.Headers))
+	for name, value := range config.Headers {
+		withHeaders.headers[name] = os.ExpandEnv(value)
+	}
+	http.DefaultTransport = withHeaders
+	if len(hosts) > 1 {
+		http.DefaultTransport = &failoverTransport{base: withHeaders, hosts: hosts}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ToolTriggerConfig decides whether a user message is worth running the
+// tools model for. Every check is disabled when left at its zero value.
+type ToolTriggerConfig struct {
+	MinLength    int    `yaml:"min_length"`
+	SkipRegex    string `yaml:"skip_regex"`
+	TriggerRegex string `yaml:"trigger_regex"`
+}
+
+func (t ToolTriggerConfig) validate() error {
+	if _, err := regexp.Compile(t.SkipRegex); err != nil {
+		return fmt.Errorf("invalid tool_trigger.skip_regex: %w", err)
+	}
+	if _, err := regexp.Compile(t.TriggerRegex); err != nil {
+		return fmt.Errorf("invalid tool_trigger.trigger_regex: %w", err)
+	}
+	return nil
+}
+
+func (t ToolTriggerConfig) shouldQueryTools(input string) bool {
+	input = strings.TrimSpace(input)
+	if len([]rune(input)) < t.MinLength {
+		return false
+	}
+	if t.SkipRegex != "" && regexp.MustCompile(t.SkipRegex).MatchString(input) {
+		return false
+	}
+	if t.TriggerRegex != "" && !regexp.MustCompile(t.TriggerRegex).MatchString(input) {
+		return false
+	}
+	return true
+}
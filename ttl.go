@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// evictExpired deletes the messages older than message_ttl, judged by the
+// time in their ids (see generateMsgID). System and pinned messages are
+// kept, as are records whose id carries no time.
+func (app *App) evictExpired() {
+	ttl, _ := time.ParseDuration(app.config.MessageTTL)
+	if ttl <= 0 {
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Warning: Failed to read conversation for message_ttl: %v\n", err)
+		return
+	}
+	cutoff := time.Now().Add(-ttl).UnixNano()
+	evicted := 0
+	for _, record := range records {
+		if record.Role == RoleSystem || app.pins[record.Id] {
+			continue
+		}
+		created, err := strconv.ParseInt(record.Id, 10, 64)
+		if err != nil || created >= cutoff {
+			continue
+		}
+		if err := app.conversation.Delete(record.Id); err != nil {
+			systemColor.Printf("Warning: Failed to evict message: %v\n", err)
+			continue
+		}
+		delete(app.tags, record.Id)
+		evicted++
+	}
+	if evicted > 0 && app.verbose {
+		systemColor.Printf("Evicted %d message(s) older than %s.\n", evicted, ttl)
+	}
+}
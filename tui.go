@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/fatih/color"
+)
+
+const tuiInputHeight = 3
+
+var (
+	tuiStatusStyle = lipgloss.NewStyle().Reverse(true)
+	tuiInputStyle  = lipgloss.NewStyle().BorderStyle(lipgloss.NormalBorder()).BorderTop(true)
+)
+
+type tuiOutputMsg string
+
+type tuiTurnDoneMsg struct {
+	err error
+}
+
+// tuiPromptMsg asks for the answer to a prompt of confirm or choose, which
+// is typed in the input box and sent on answer. answer is closed when the
+// prompt is cancelled.
+type tuiPromptMsg struct {
+	answer chan<- string
+}
+
+// tuiWriter forwards everything the turn orchestration prints to the
+// conversation pane.
+type tuiWriter struct {
+	program *tea.Program
+	// done is closed once the program has exited.
+	done <-chan struct{}
+}
+
+func (w tuiWriter) Write(p []byte) (int, error) {
+	w.program.Send(tuiOutputMsg(p))
+	return len(p), nil
+}
+
+// readAnswer replaces the stdin prompt reader while the TUI runs. A
+// cancelled prompt, or one left when the program exits, reads as the end
+// of the input.
+func (w tuiWriter) readAnswer() (string, error) {
+	answer := make(chan string, 1)
+	w.program.Send(tuiPromptMsg{answer: answer})
+	select {
+	case text, ok := <-answer:
+		if !ok {
+			return "", io.EOF
+		}
+		return text + "\n", nil
+	case <-w.done:
+		return "", io.EOF
+	}
+}
+
+type tuiModel struct {
+	app        *App
+	viewport   viewport.Model
+	input      textarea.Model
+	transcript *strings.Builder
+	width      int
+	ready      bool
+	busy       bool
+	status     tuiStatus
+	// prompt receives the next input while a command waits for an answer.
+	prompt chan<- string
+}
+
+// tuiStatus is a snapshot of the values shown in the status bar, taken
+// between turns so the view never reads App while a turn is running.
+type tuiStatus struct {
+	model        string
+	temperature  float64
+	promptTokens int
+	evalTokens   int
+	tools        string
+	window       string
+	format       string
+}
+
+func newTUIStatus(app *App) tuiStatus {
+	return tuiStatus{
+		model:        app.config.ChatModel,
+		temperature:  app.config.Temperature,
+		promptTokens: app.lastAnswer.PromptEvalCount,
+		evalTokens:   app.lastAnswer.EvalCount,
+		tools:        toolsState(app),
+		window:       app.config.historyWindow(),
+		format:       app.responseFormat.String(),
+	}
+}
+
+func toolsState(app *App) string {
+	switch {
+	case len(app.ollamaTools) == 0:
+		return "none"
+	case app.toolsPaused:
+		return "off"
+	}
+	return "on"
+}
+
+func newTUIModel(app *App) tuiModel {
+	input := textarea.New()
+	input.Placeholder = "Send a message..."
+	input.ShowLineNumbers = false
+	input.SetHeight(tuiInputHeight)
+	input.KeyMap.InsertNewline.SetKeys("alt+enter", "ctrl+j")
+	input.Focus()
+
+	return tuiModel{
+		app:        app,
+		input:      input,
+		transcript: &strings.Builder{},
+		status:     newTUIStatus(app),
+	}
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		paneHeight := msg.Height - tuiInputHeight - 2
+		if !m.ready {
+			m.viewport = viewport.New(msg.Width, paneHeight)
+			m.ready = true
+		} else {
+			m.viewport.Width = msg.Width
+			m.viewport.Height = paneHeight
+		}
+		m.input.SetWidth(msg.Width)
+		m.refresh()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			if m.prompt != nil {
+				close(m.prompt)
+				m.prompt = nil
+				return m, nil
+			}
+			return m, tea.Quit
+		case "enter":
+			text := m.input.Value()
+			m.input.Reset()
+			if m.prompt != nil {
+				m.transcript.WriteString(text + "\n")
+				m.refresh()
+				m.prompt <- text
+				m.prompt = nil
+				return m, nil
+			}
+			return m.submit(text)
+		case "tab":
+			m.completeCommand()
+			return m, nil
+		case "ctrl+r":
+			return m.submit("/reload")
+		case "ctrl+t":
+			return m.submit("/lasttool")
+		case "pgup", "pgdown":
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+
+	case tuiOutputMsg:
+		m.transcript.WriteString(string(msg))
+		m.refresh()
+		return m, nil
+
+	case tuiPromptMsg:
+		m.prompt = msg.answer
+		return m, nil
+
+	case tuiTurnDoneMsg:
+		m.busy = false
+		m.status = newTUIStatus(m.app)
+		if msg.err != nil {
+			m.transcript.WriteString(systemColor.Sprintf("Error: %v\n", msg.err))
+		}
+		m.refresh()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m tuiModel) submit(text string) (tea.Model, tea.Cmd) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return m, nil
+	}
+	if text == "exit" || text == "quit" {
+		return m, tea.Quit
+	}
+	if m.busy {
+		m.transcript.WriteString(systemColor.Sprintln("A turn is still running, please wait."))
+		m.refresh()
+		return m, nil
+	}
+
+	m.busy = true
+	m.transcript.WriteString(userColor.Sprint("You: ") + text + "\n")
+	m.refresh()
+
+	app := m.app
+	return m, func() tea.Msg {
+		if strings.HasPrefix(text, "/") {
+			app.withSession(func() { app.handleCommand(text) })
+			return tuiTurnDoneMsg{}
+		}
+		var err error
+		app.withSession(func() { err = app.processTurn(text) })
+		return tuiTurnDoneMsg{err: err}
+	}
+}
+
+// completeCommand completes a slash command in the input box, listing the
+// candidates when the prefix is ambiguous.
+func (m *tuiModel) completeCommand() {
+	value := m.input.Value()
+	if !strings.HasPrefix(value, "/") || strings.Contains(value, " ") {
+		return
+	}
+	candidates := commandCandidates(value)
+	switch len(candidates) {
+	case 0:
+		return
+	case 1:
+		m.input.SetValue(candidates[0] + " ")
+	default:
+		m.input.SetValue(commonPrefix(candidates))
+		m.transcript.WriteString(systemColor.Sprintf("Commands: %s\n", strings.Join(candidates, " ")))
+		m.refresh()
+	}
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+func (m *tuiModel) refresh() {
+	if !m.ready {
+		return
+	}
+	m.viewport.SetContent(lipgloss.NewStyle().Width(m.width).Render(m.transcript.String()))
+	m.viewport.GotoBottom()
+}
+
+func (m tuiModel) statusLine() string {
+	state := "ready"
+	switch {
+	case m.prompt != nil:
+		state = "waiting for an answer"
+	case m.busy:
+		state = "thinking..."
+	}
+	status := fmt.Sprintf(" %s | temp %.2f | tokens: %d in, %d out | window: %s | tools: %s | format: %s | %s | enter: send, alt+enter: newline, ctrl+r: /reload, ctrl+t: /lasttool, ctrl+c: quit",
+		m.status.model, m.status.temperature, m.status.promptTokens, m.status.evalTokens, m.status.window, m.status.tools, m.status.format, state)
+	return tuiStatusStyle.Width(m.width).MaxWidth(m.width).Render(status)
+}
+
+func (m tuiModel) View() string {
+	if !m.ready {
+		return "Loading..."
+	}
+	return m.viewport.View() + "\n" + m.statusLine() + "\n" + tuiInputStyle.Render(m.input.View())
+}
+
+// runTUI runs the interactive terminal UI until the user quits. All output
+// produced by the turn orchestration is redirected into the conversation
+// pane while it runs, and prompts are answered in the input box.
+func runTUI(app *App) error {
+	program := tea.NewProgram(newTUIModel(app), tea.WithAltScreen())
+
+	done := make(chan struct{})
+	writer := tuiWriter{program: program, done: done}
+	previousOutput := color.Output
+	previousReadAnswer := readAnswer
+	app.out = writer
+	color.Output = writer
+	readAnswer = writer.readAnswer
+	defer func() {
+		app.out = os.Stdout
+		color.Output = previousOutput
+		readAnswer = previousReadAnswer
+	}()
+
+	_, err := program.Run()
+	close(done)
+	return err
+}
@@ -0,0 +1,686 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/enums/option"
+	"github.com/parakeet-nest/parakeet/llm"
+	mcpstdio "github.com/parakeet-nest/parakeet/mcp-stdio"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errTurnBusy rejects input that arrives while the session is still
+// answering.
+var errTurnBusy = errors.New("a turn is still running, please wait for it to finish")
+
+// RunTurn answers input in session: it builds the request from the
+// session's history, runs the tools loop, streams the answer to the
+// session's output and saves every message. Calls for different sessions
+// may run concurrently; a call for a session that is already running a
+// turn fails with errTurnBusy.
+func RunTurn(ctx context.Context, session *App, input string) (llm.Answer, error) {
+	response, err := session.runTurn(ctx, input, "", turnNew)
+	answer := session.lastAnswer
+	answer.Message = llm.Message{Role: RoleAssistant, Content: response}
+	return answer, err
+}
+
+func (app *App) processTurn(userInput string) error {
+	_, err := RunTurn(app.ctx, app, userInput)
+	return err
+}
+
+// turnKind tells runTurn where the message to answer comes from.
+type turnKind int
+
+const (
+	// turnNew answers a new user message.
+	turnNew turnKind = iota
+	// turnRegenerate answers the last user message of the conversation
+	// again.
+	turnRegenerate
+	// turnInjected answers the tool result /inject added last. The tools
+	// are not queried first and the turn is not counted.
+	turnInjected
+)
+
+// runTurn answers userInput with the given chat model, or the one
+// chatModelFor picks when model is empty, and returns the final response.
+// Unless kind is turnNew, the message to answer is already the last one in
+// the conversation and is not saved again.
+func (app *App) runTurn(ctx context.Context, userInput, model string, kind turnKind) (finalResponse string, err error) {
+	// Only one turn at a time may read and write the conversation.
+	if !app.turnMu.TryLock() {
+		return "", errTurnBusy
+	}
+	defer app.turnMu.Unlock()
+
+	start := time.Now()
+	markActivity()
+	defer markActivity()
+	ctx, span := tracer.Start(ctx, "turn")
+	defer func() {
+		endSpan(span, err)
+		observeTurn(start, err)
+		status := progressDone
+		if err != nil {
+			status = progressFailed
+		}
+		app.writeProgress(progressTurn, "", status)
+	}()
+
+	if kind == turnNew {
+		if userInput, err = app.filterInput(userInput); err != nil {
+			return "", err
+		}
+	}
+	if model == "" {
+		model = app.chatModelFor(userInput)
+	}
+
+	app.turnToolCalls = nil
+	app.agentStep = 0
+	app.usage = turnUsage{}
+	app.turnSources = nil
+	app.turnThinking.Reset()
+	app.variants = nil
+	app.canContinue = false
+	app.evictExpired()
+
+	liveInput := app.expandVars(app.config.augmentUserMessage(userInput))
+	storedInput := app.expandVars(userInput)
+	if app.config.StoreAugmentedMessage {
+		storedInput = liveInput
+	}
+
+	if kind == turnNew {
+		id := generateMsgID()
+		err = app.conversation.Save(id, llm.Message{
+			Role:    RoleUser,
+			Content: storedInput,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to save user message: %w", err)
+		}
+		app.lastUserMsgID = id
+		app.lastUserInput = userInput
+	}
+
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to get conversation history: %w", err)
+	}
+	allMessages := messagesOf(records)
+
+	history := getLastMessages(allMessages, app.config, app.pinnedIndexes(records))
+	if kind != turnInjected {
+		history[len(history)-1].Content = liveInput
+	}
+
+	chatOptions := app.chatOptions()
+
+	if kind != turnInjected {
+		app.teePrintf("\n--- %s ---\nYou: %s\n", time.Now().Format(time.RFC3339), userInput)
+	}
+
+	// Refusals are retried at most once per turn.
+	retried := false
+	// The last saved answer gets the sources footer.
+	var answerID string
+	var answerMessage llm.Message
+	saveAnswer := func(response string) error {
+		finalResponse = response
+		if app.config.RedactHistory {
+			response = newRedactor(app.config.Redact).redact(response)
+		}
+		answerID = generateMsgID()
+		answerMessage = llm.Message{Role: RoleAssistant, Content: response}
+		history = append(history, answerMessage)
+		if err := app.conversation.Save(answerID, answerMessage); err != nil {
+			return fmt.Errorf("failed to save assistant response: %w", err)
+		}
+		return nil
+	}
+	maxSteps := 0
+	if app.config.AgentMode {
+		maxSteps = app.config.AgentMaxSteps
+	}
+	toolsAvailable := len(app.ollamaTools) > 0 && !app.toolsPaused
+	// An injected result is answered as it is, without calling a real tool
+	// first.
+	firstToolsQuery := kind != turnInjected
+	if app.config.SingleModelTools && toolsAvailable && firstToolsQuery {
+		// The chat model handles the tool calls itself, so the legacy
+		// tools model loop below is skipped.
+		var response string
+		history, response, err = app.runSingleModelTools(ctx, history, model, chatOptions)
+		if err != nil {
+			return "", err
+		}
+		if err := saveAnswer(response); err != nil {
+			return "", err
+		}
+		maxSteps = -1
+	}
+	for step := 0; step <= maxSteps; step++ {
+		app.agentStep = step
+		if toolsAvailable && (step > 0 || firstToolsQuery && app.config.ToolTrigger.shouldQueryTools(userInput)) {
+			if step > 0 {
+				systemColor.Printf("%s Agent step %d/%d: checking for further tool calls...\n", iconAgent, step, maxSteps)
+			}
+			app.writeProgress(progressToolsCheck, "", progressStarted)
+			var toolCalled bool
+			history, toolCalled, err = app.runTools(ctx, history)
+			if err != nil {
+				return "", err
+			}
+			if !toolCalled {
+				app.writeProgress(progressToolsCheck, "", progressNoTool)
+			}
+			if step > 0 && !toolCalled {
+				break
+			}
+		} else if step > 0 {
+			break
+		}
+
+		for inlineCalls := 0; ; inlineCalls++ {
+			app.writeProgress(progressAnswer, "", progressStarted)
+			response, call, err := app.streamChat(ctx, llm.Query{
+				Model:    model,
+				Messages: app.buildMessages(history),
+				Options:  chatOptions,
+				Format:   app.responseFormat.query(),
+			})
+			if err == nil && call == nil && !retried && app.config.RefusalRetry.isRefusal(response) {
+				retried = true
+				systemColor.Println("Answer looks like a refusal, retrying once with a clarifying instruction...")
+				clarified := append(slices.Clip(history), llm.Message{Role: RoleUser, Content: app.config.RefusalRetry.Instruction})
+				response, call, err = app.streamChat(ctx, llm.Query{
+					Model:    model,
+					Messages: app.buildMessages(clarified),
+					Options:  chatOptions,
+					Format:   app.responseFormat.query(),
+				})
+			}
+			if err != nil {
+				app.writeProgress(progressAnswer, "", progressFailed)
+				if response != "" {
+					return response, app.savePartialResponse(response, err)
+				}
+				// Interrupted before any answer text, e.g. while the model
+				// was still thinking: nothing to keep, but the session goes on.
+				if errors.Is(err, errInterrupted) {
+					return "", fmt.Errorf("%w: response %v", errTurnAborted, err)
+				}
+				// A wrong model name ends the turn, not the session.
+				if hint, ok := missingModelHint(app.config.apiURL(), err); ok {
+					return "", fmt.Errorf("%w: %s", errTurnAborted, hint)
+				}
+				return "", fmt.Errorf("failed to get response from LLM: %w", err)
+			}
+
+			if err := saveAnswer(response); err != nil {
+				return "", err
+			}
+			app.writeProgress(progressAnswer, "", progressDone)
+
+			if call == nil {
+				break
+			}
+			if inlineCalls >= app.config.InlineMaxCalls {
+				systemColor.Println("Warning: Too many inline tool calls, stopping this turn.")
+				break
+			}
+			history, err = app.runInlineToolCall(ctx, history, *call)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if problem := app.responseFormat.check(finalResponse); problem != "" && finalResponse != "" {
+		systemColor.Printf("Warning: %s.\n", problem)
+	}
+	if footer := app.sourcesFooter(); footer != "" && answerID != "" {
+		toolColor.Println(strings.TrimPrefix(footer, "\n"))
+		finalResponse += footer
+		answerMessage.Content += footer
+		if err := app.conversation.Save(answerID, answerMessage); err != nil {
+			return "", fmt.Errorf("failed to save assistant response: %w", err)
+		}
+	}
+	app.reportUsage()
+	app.writeThinking(model)
+
+	if kind != turnInjected {
+		app.turn++
+		if app.outDir != "" {
+			if err := app.writeTurnFile(userInput, finalResponse); err != nil {
+				systemColor.Printf("Warning: Failed to write the answer to %s: %v\n", app.outDir, err)
+			}
+		}
+		if app.extractDir != "" {
+			app.extractCode(finalResponse)
+		}
+	}
+
+	if app.outputFormat != OutputText {
+		err = writeTurnOutput(os.Stdout, app.outputFormat, turnOutput{
+			Model:     model,
+			Content:   finalResponse,
+			ToolCalls: app.turnToolCalls,
+			Stats: turnStats{
+				PromptTokens:     app.lastAnswer.PromptEvalCount,
+				CompletionTokens: app.lastAnswer.EvalCount,
+				DurationSeconds:  time.Since(start).Seconds(),
+				Cost:             app.usage.cost,
+			},
+		})
+		if err != nil {
+			return finalResponse, fmt.Errorf("failed to write %s output: %w", app.outputFormat, err)
+		}
+	}
+
+	return finalResponse, nil
+}
+
+// chatOptions returns the sampling options of the chat model for the
+// current turn.
+func (app *App) chatOptions() llm.Options {
+	chatOptionValues := map[string]any{
+		option.Temperature:   app.config.TemperatureSchedule.temperatureFor(app.config.Temperature, app.turn),
+		option.RepeatLastN:   app.config.RepeatLastN,
+		option.RepeatPenalty: app.config.RepeatPenalty,
+		option.NumCtx:        app.config.NumCtx,
+		option.Mirostat:      1,
+		option.MirostatTau:   5.0,
+		option.MirostatEta:   0.1,
+	}
+	if app.config.TopK > 0 {
+		chatOptionValues[option.TopK] = app.config.TopK
+	}
+	if app.config.TopP > 0 {
+		chatOptionValues[option.TopP] = app.config.TopP
+	}
+	for key, value := range app.chatOptionOverrides {
+		chatOptionValues[key] = value
+	}
+	return llm.SetOptions(chatOptionValues)
+}
+
+// toolsOptions returns the sampling options of the tools model.
+func (app *App) toolsOptions() llm.Options {
+	toolsOptionValues := map[string]any{
+		option.Temperature:   app.config.ToolsTemperature,
+		option.RepeatLastN:   app.config.ToolsRepeatLastN,
+		option.RepeatPenalty: app.config.ToolsRepeatPenalty,
+		option.NumCtx:        app.config.NumCtx,
+		option.Mirostat:      1,
+		option.MirostatTau:   1.0,
+		option.MirostatEta:   0.1,
+		option.TopK:          40,
+		option.TopP:          0.9,
+	}
+	for key, value := range app.toolsOptionOverrides {
+		toolsOptionValues[key] = value
+	}
+	return llm.SetOptions(toolsOptionValues)
+}
+
+// streamChat streams the chat model's answer to the output and returns the
+// full response. With inline tool calls enabled, streaming stops at the
+// first tool call found in the answer, which is returned alongside the
+// response up to that point.
+func (app *App) streamChat(ctx context.Context, query llm.Query) (string, *inlineToolCall, error) {
+	label := "LLoms: "
+	if query.Model != app.config.ChatModel {
+		label = fmt.Sprintf("LLoms (%s): ", query.Model)
+	}
+	assistantColor.Print(label)
+	app.teePrintf("%s", label)
+	paged := app.usePager()
+	if paged {
+		systemColor.Print("(generating...)")
+	}
+	var inlinePattern *regexp.Regexp
+	if app.config.InlineToolCalls && len(app.ollamaTools) > 0 {
+		inlinePattern = regexp.MustCompile(app.config.InlineToolCallPattern)
+	}
+	var inlineCall *inlineToolCall
+	// Without tools, calls the model writes anyway can be shown as notes.
+	var notes toolNoteStream
+	if app.config.ToolCallNotes && len(app.ollamaTools) == 0 {
+		notes = newToolNoteStream(app.config.ToolCallNotePattern)
+	}
+	// Ctrl-C stops the stream instead of the program; the partial answer is
+	// kept so it can be resumed with /continue.
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	query.Stream = true
+	app.recordExchange(query, llm.Answer{})
+	_, span := tracer.Start(ctx, "chat", trace.WithAttributes(attribute.String("llm.model", query.Model)))
+	var assistantResponse strings.Builder
+	redacted := &redactStream{redactor: newRedactor(app.config.Redact)}
+	var pagerBuffer *strings.Builder
+	if paged {
+		pagerBuffer = &strings.Builder{}
+	}
+	sink, sinkDone := app.streamSinks(pagerBuffer, interrupts)
+	show := func(text string) {
+		io.WriteString(sink, text)
+	}
+	var thinking *thinkStream
+	if app.thinkingFile != nil || app.showThinking {
+		thinking = &thinkStream{}
+	}
+	// split keeps the thinking of reasoning models out of the answer.
+	split := func(thought, content string) string {
+		app.turnThinking.WriteString(thought)
+		if app.showThinking && !paged && thought != "" {
+			fmt.Fprint(app.out, chunkColor.Sprint(thought))
+		}
+		return content
+	}
+	// Ctrl-C cancels the request, so it also stops a model that has not
+	// sent anything yet.
+	streamCtx, cancel := context.WithCancel(ctx)
+	var interrupted atomic.Bool
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		select {
+		case <-interrupts:
+			interrupted.Store(true)
+			cancel()
+		case <-streamCtx.Done():
+		}
+	}()
+	setChatStreamContext(streamCtx)
+	_, err := completion.ChatStream(app.config.apiURL(), query,
+		func(answer llm.Answer) error {
+			if interrupted.Load() {
+				return errInterrupted
+			}
+			content := answer.Message.Content
+			if thinking != nil {
+				content = split(thinking.write(content))
+			}
+			content = notes.write(content)
+			show(redacted.write(content))
+			if !paged && app.showChunks {
+				fmt.Fprint(app.out, chunkColor.Sprint("|"))
+			}
+			assistantResponse.WriteString(content)
+			if answer.Done {
+				answer.Message.Role = app.config.RoleMap.incoming(answer.Message.Role)
+				app.lastAnswer = answer
+				final := answer
+				final.Message.Content = assistantResponse.String()
+				app.recordExchange(query, final)
+				app.addUsage(query.Model, answer)
+				span.SetAttributes(
+					attribute.Int("llm.prompt_tokens", answer.PromptEvalCount),
+					attribute.Int("llm.completion_tokens", answer.EvalCount),
+				)
+			}
+			if inlinePattern != nil {
+				text := assistantResponse.String()
+				if call, end, found := findInlineToolCall(inlinePattern, text); found {
+					inlineCall = &call
+					assistantResponse.Reset()
+					assistantResponse.WriteString(text[:end])
+					return errInlineToolCall
+				}
+			}
+			return nil
+		},
+	)
+	setChatStreamContext(nil)
+	cancel()
+	<-watching
+	if interrupted.Load() {
+		err = errInterrupted
+	} else if errors.Is(err, errInlineToolCall) {
+		err = nil
+	}
+	if inlineCall == nil {
+		var content string
+		if thinking != nil {
+			content = split(thinking.flush())
+		}
+		content = notes.write(content) + notes.flush()
+		show(redacted.write(content))
+		assistantResponse.WriteString(content)
+	}
+	show(redacted.flush())
+	endSpan(span, err)
+	observeCompletion("chat", app.lastAnswer, err)
+	// Timed after the span so that a slow display does not count.
+	sinkDone(errors.Is(err, errInterrupted))
+	if err != nil {
+		fmt.Fprintln(app.out)
+		return assistantResponse.String(), nil, err
+	}
+	if paged {
+		fmt.Fprintln(app.out)
+		text := pagerBuffer.String()
+		if err := showInPager(text); err != nil {
+			systemColor.Printf("Pager failed: %v\n", err)
+			fmt.Fprint(app.out, text)
+		}
+	}
+	fmt.Fprintln(app.out)
+	app.teePrintf("\n")
+	return assistantResponse.String(), inlineCall, nil
+}
+
+// runTools asks the tools model whether a tool should be called and, if so,
+// calls it and returns the history extended with its result. The boolean
+// reports whether the tools model requested a tool. An error means the turn
+// must be aborted.
+func (app *App) runTools(ctx context.Context, history []llm.Message) ([]llm.Message, bool, error) {
+	toolsOptions := app.toolsOptions()
+
+	toolsQuery := llm.Query{
+		Model:    app.config.ToolsModel,
+		Messages: app.buildMessages(history),
+		Tools:    app.ollamaTools,
+		Options:  toolsOptions,
+		Format:   "json",
+	}
+
+	_, span := tracer.Start(ctx, "tools_query", trace.WithAttributes(attribute.String("llm.model", toolsQuery.Model)))
+	var answer llm.Answer
+	var err error
+	if app.config.ToolsEarlyAbort {
+		var prose bool
+		answer, prose, err = app.streamToolsQuery(toolsQuery)
+		if prose {
+			span.SetAttributes(attribute.Bool("llm.early_abort", true))
+			endSpan(span, nil)
+			return history, false, nil
+		}
+	} else {
+		answer, err = completion.Chat(app.config.apiURL(), toolsQuery)
+	}
+	app.recordExchange(toolsQuery, answer)
+	answer.Message.Role = app.config.RoleMap.incoming(answer.Message.Role)
+	app.addUsage(toolsQuery.Model, answer)
+	if err == nil {
+		span.SetAttributes(
+			attribute.Int("llm.prompt_tokens", answer.PromptEvalCount),
+			attribute.Int("llm.completion_tokens", answer.EvalCount),
+		)
+		for _, toolCall := range answer.Message.ToolCalls {
+			span.SetAttributes(attribute.String("llm.tool_call", toolCall.Function.Name))
+		}
+	}
+	endSpan(span, err)
+	observeCompletion("tools_query", answer, err)
+
+	if err != nil {
+		if hint, ok := missingModelHint(app.config.apiURL(), err); ok {
+			err = errors.New(hint)
+		}
+		systemColor.Printf("Tools check failed: %v\n", err)
+		systemColor.Println("Continuing with standard chat...")
+		return history, false, nil
+	}
+	if len(answer.Message.ToolCalls) == 0 {
+		return history, false, nil
+	}
+
+	history, err = app.executeToolCall(ctx, history, answer.Message.ToolCalls[0])
+	return history, true, err
+}
+
+// executeToolCall runs a tool call requested by the model, falling back to
+// a tool with a similar name, and adds its result to the history.
+func (app *App) executeToolCall(ctx context.Context, history []llm.Message, toolCall llm.ToolCall) ([]llm.Message, error) {
+	similarTool, found := findSimilarTool(toolCall.Function.Name, app.ollamaTools)
+	if !found {
+		systemColor.Printf("Warning: Tool '%s' does not exist and no similar tools found. Continuing with standard chat...\n",
+			toolCall.Function.Name)
+		return history, nil
+	}
+	if similarTool != toolCall.Function.Name {
+		toolColor.Printf("%s Using similar tool: '%s' instead of '%s'\n",
+			iconTool, similarTool, toolCall.Function.Name)
+	}
+	toolColor.Printf("%s Calling tool: %s with args: %s\n",
+		iconTool, similarTool, toolCall.Function.Arguments)
+	mcpResult, err := app.callTool(ctx, similarTool, toolCall.Function.Arguments)
+	result := mcpResult.Text
+	if err != nil {
+		if result, err = app.handleToolError(similarTool, err); err != nil {
+			return history, err
+		}
+	}
+
+	err = app.conversation.Save(generateMsgID(), llm.Message{
+		Role:    RoleAssistant,
+		Content: fmt.Sprintf(toolUsedFormat, toolCall.Function.Name),
+	})
+	if err != nil {
+		systemColor.Printf("Tool call failed: %v\n", err)
+	}
+	history = append(history, llm.Message{Role: RoleAssistant, Content: fmt.Sprintf(toolUsedFormat, toolCall.Function.Name)})
+
+	return app.recordToolResult(history, similarTool, result), nil
+}
+
+// recordToolResult shows a tool result and adds it to the history and the
+// conversation as user messages, split into parts when it is larger than
+// tool_result_chunk_bytes.
+func (app *App) recordToolResult(history []llm.Message, toolName, result string) []llm.Message {
+	app.lastToolName = toolName
+	app.lastToolResult = result
+	app.collectSources(toolName, result)
+	contentFromTool := truncateToolResult(app.postProcessToolResult(toolName, result), app.config.MaxToolResultBytes)
+	redaction := newRedactor(app.config.Redact)
+	toolColor.Printf("%s Tool result: %v\n",
+		iconTool, redaction.redact(contentFromTool))
+	if summary, ok := app.summarizeToolResult(toolName, contentFromTool); ok {
+		toolColor.Printf("%s Summarized result: %v\n", iconTool, redaction.redact(summary))
+		contentFromTool = summary
+	}
+	if app.config.RedactHistory {
+		contentFromTool = redaction.redact(contentFromTool)
+	}
+	contentFromTool = app.stripInjections(toolName, contentFromTool)
+
+	parts := splitToolResult(contentFromTool, app.config.ToolResultChunkBytes)
+	for i, part := range parts {
+		part = app.wrapToolResult(toolName, part)
+		if len(parts) > 1 {
+			part = fmt.Sprintf("[part %d/%d]\n%s", i+1, len(parts), part)
+		}
+		history = append(history, llm.Message{Role: RoleUser, Content: part})
+
+		err := app.conversation.Save(generateMsgID(), llm.Message{
+			Role:    RoleUser,
+			Content: part,
+		})
+		if err != nil {
+			systemColor.Printf("Tool result failed: %v\n", err)
+		}
+	}
+	return history
+}
+
+// splitToolResult splits text into parts of at most chunkBytes bytes,
+// preferring to break after a newline and never splitting a UTF-8
+// character. A chunkBytes of zero or less disables splitting.
+func splitToolResult(text string, chunkBytes int) []string {
+	if chunkBytes <= 0 || len(text) <= chunkBytes {
+		return []string{text}
+	}
+	var parts []string
+	for len(text) > chunkBytes {
+		cut := strings.LastIndexByte(text[:chunkBytes], '\n') + 1
+		if cut == 0 {
+			cut = chunkBytes
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = chunkBytes
+			}
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+func (app *App) callTool(ctx context.Context, name string, arguments map[string]any) (mcpstdio.CallToolResult, error) {
+	if app.safeModeBlocks(name) {
+		app.turnToolCalls = append(app.turnToolCalls, turnToolCall{Name: name, Arguments: arguments, Error: "blocked in safe mode"})
+		app.writeProgress(progressToolCall, name, progressBlocked)
+		return refuseTool(name, arguments), nil
+	}
+	if matchesToolPattern(name, app.config.ConfirmTools) && !app.confirmTool(name, arguments) {
+		app.turnToolCalls = append(app.turnToolCalls, turnToolCall{Name: name, Arguments: arguments, Error: "declined by the user"})
+		app.writeProgress(progressToolCall, name, progressDeclined)
+		return declineTool(name), nil
+	}
+	app.writeProgress(progressToolCall, name, progressStarted)
+	_, span := tracer.Start(ctx, "tool_call", trace.WithAttributes(attribute.String("tool.name", name)))
+	var result mcpstdio.CallToolResult
+	var err error
+	if stub, ok := app.staticTools[name]; ok {
+		result = mcpstdio.CallToolResult{Type: "text", Text: stub}
+	} else if app.mcpActive {
+		result, err = app.callMCPTool(name, arguments)
+	} else {
+		err = fmt.Errorf("no MCP server provides tool %s", name)
+	}
+	call := turnToolCall{Name: name, Arguments: arguments}
+	status := progressOK
+	if err != nil {
+		call.Error = err.Error()
+		status = progressFailed
+	}
+	app.turnToolCalls = append(app.turnToolCalls, call)
+	app.writeProgress(progressToolCall, name, status)
+	endSpan(span, err)
+	observeToolCall(name, err)
+	return result, err
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// typeCatchUp bounds how far --type-delay lags behind the model: every
+// tick writes one character, plus one for each typeCatchUp characters
+// waiting, so a long backlog is shown within about typeCatchUp ticks.
+const typeCatchUp = 32
+
+// typeWriter paces the display of a streamed answer for --type-delay: text
+// is buffered as it arrives and written a character or so per tick,
+// whatever the speed of the model.
+type typeWriter struct {
+	out io.Writer
+	// mu is held while writing to out too, so that Flush keeps the order
+	// of the text.
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+	done    chan struct{}
+}
+
+func newTypeWriter(out io.Writer, delay time.Duration) *typeWriter {
+	w := &typeWriter{out: out, done: make(chan struct{})}
+	go w.run(delay)
+	return w
+}
+
+func (w *typeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	return len(p), nil
+}
+
+func (w *typeWriter) run(delay time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(delay)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.mu.Lock()
+		if len(w.pending) == 0 {
+			closed := w.closed
+			w.mu.Unlock()
+			if closed {
+				return
+			}
+			continue
+		}
+		size := 0
+		for chars := 1 + utf8.RuneCount(w.pending)/typeCatchUp; chars > 0 && size < len(w.pending); chars-- {
+			_, n := utf8.DecodeRune(w.pending[size:])
+			size += n
+		}
+		w.out.Write(w.pending[:size])
+		w.pending = w.pending[size:]
+		w.mu.Unlock()
+	}
+}
+
+// Flush writes everything still pending at once.
+func (w *typeWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.out.Write(w.pending)
+	w.pending = nil
+}
+
+// Close waits until everything written has been displayed, or flushes it
+// when an interrupt arrives in the meantime.
+func (w *typeWriter) Close(interrupts <-chan os.Signal) error {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+	case <-interrupts:
+		w.Flush()
+		<-w.done
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	varPattern     = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	varNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// expandVars replaces {{name}} with the value of the session variable name.
+// Unknown names are left as they are.
+func (app *App) expandVars(text string) string {
+	if len(app.vars) == 0 {
+		return text
+	}
+	return varPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := varPattern.FindStringSubmatch(match)[1]
+		if value, ok := app.vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+func (app *App) varCommand(args string) {
+	action, rest, _ := strings.Cut(args, " ")
+	switch action {
+	case "", "list":
+		if len(app.vars) == 0 {
+			systemColor.Println("No variables. Use /var set <name> <value> to add one.")
+			return
+		}
+		names := make([]string, 0, len(app.vars))
+		for name := range app.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			systemColor.Printf("  %s = %s\n", name, app.vars[name])
+		}
+	case "set":
+		name, value, _ := strings.Cut(strings.TrimSpace(rest), " ")
+		if !varNamePattern.MatchString(name) {
+			systemColor.Println("Usage: /var set <name> <value> (names are letters, digits and _)")
+			return
+		}
+		if app.vars == nil {
+			app.vars = map[string]string{}
+		}
+		app.vars[name] = strings.TrimSpace(value)
+		systemColor.Printf("Set {{%s}}.\n", name)
+	case "unset":
+		name := strings.TrimSpace(rest)
+		if _, ok := app.vars[name]; !ok {
+			systemColor.Printf("Unknown variable: %s\n", name)
+			return
+		}
+		delete(app.vars, name)
+		systemColor.Printf("Removed {{%s}}.\n", name)
+	default:
+		systemColor.Println("Usage: /var [list|set <name> <value>|unset <name>]")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const defaultVariantCount = 3
+
+func (app *App) varyCommand(args []string) {
+	if len(args) == 2 && args[0] == "pick" {
+		app.pickVariant(args[1])
+		return
+	}
+	if len(args) == 0 || len(args) > 2 {
+		systemColor.Println("Usage: /vary <temperature> [count] | /vary pick <n>")
+		return
+	}
+	temperature, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || temperature < 0 || temperature > 2 {
+		systemColor.Printf("Invalid temperature %q: expected a number between 0 and 2\n", args[0])
+		return
+	}
+	count := defaultVariantCount
+	if len(args) == 2 {
+		if count, err = strconv.Atoi(args[1]); err != nil || count < 1 {
+			systemColor.Printf("Invalid count %q: expected a positive number\n", args[1])
+			return
+		}
+	}
+	app.generateVariants(temperature, count)
+}
+
+// generateVariants answers the last user message count times at the given
+// temperature and lists the answers for /vary pick. The conversation is
+// left unchanged.
+func (app *App) generateVariants(temperature float64, count int) {
+	messages, err := app.lastTurnMessages()
+	if err != nil {
+		systemColor.Printf("Vary failed: %v\n", err)
+		return
+	}
+	if messages == nil {
+		systemColor.Println("There is no message to vary the answer of yet.")
+		return
+	}
+
+	options := app.chatOptions()
+	options.Temperature = temperature
+	app.variants = nil
+	for i := 1; i <= count; i++ {
+		systemColor.Printf("Generating variant %d/%d at temperature %.2f...\n", i, count, temperature)
+		answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+			Model:    app.config.ChatModel,
+			Messages: messages,
+			Options:  options,
+		})
+		if err != nil {
+			systemColor.Printf("Failed to generate variant %d: %v\n", i, err)
+			break
+		}
+		app.addUsage(app.config.ChatModel, answer)
+		content := strings.TrimSpace(answer.Message.Content)
+		app.variants = append(app.variants, content)
+		assistantColor.Printf("--- Variant %d ---\n", len(app.variants))
+		fmt.Fprintln(app.out, newRedactor(app.config.Redact).redact(content))
+	}
+	if len(app.variants) > 0 {
+		systemColor.Println("Keep one with /vary pick <n>.")
+	}
+}
+
+// pickVariant replaces the answer to the last user message with one of
+// the variants.
+func (app *App) pickVariant(arg string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(app.variants) {
+		if len(app.variants) == 0 {
+			systemColor.Println("There are no variants to pick from; generate some with /vary <temperature> [count].")
+		} else {
+			systemColor.Printf("Invalid variant %q: expected 1 to %d\n", arg, len(app.variants))
+		}
+		return
+	}
+	found, err := app.dropLastAnswer()
+	if err != nil {
+		systemColor.Printf("Pick failed: %v\n", err)
+		return
+	}
+	if !found {
+		systemColor.Println("The message the variants answer is no longer in the conversation.")
+		return
+	}
+
+	content := app.variants[n-1]
+	if app.config.RedactHistory {
+		content = newRedactor(app.config.Redact).redact(content)
+	}
+	if err := app.conversation.Save(generateMsgID(), llm.Message{Role: RoleAssistant, Content: content}); err != nil {
+		systemColor.Printf("Failed to save variant: %v\n", err)
+		return
+	}
+	app.variants = nil
+	systemColor.Printf("Variant %d is now the answer.\n", n)
+}
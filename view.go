@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const (
+	ViewFormatText     = "text"
+	ViewFormatMarkdown = "md"
+	ViewFormatJSON     = "json"
+)
+
+var markdownHeadingColor = color.New(color.Bold)
+
+type viewOptions struct {
+	session string
+	format  string
+}
+
+// parseViewArgs parses "view <session.json> [--format md|text|json]",
+// accepting the flag before or after the session file.
+func parseViewArgs(args []string) (viewOptions, error) {
+	var options viewOptions
+	flags := flag.NewFlagSet("view", flag.ContinueOnError)
+	flags.StringVar(&options.format, "format", ViewFormatText, "")
+
+	session, err := parseSessionArgs(flags, args)
+	if err != nil {
+		return options, err
+	}
+	switch options.format {
+	case ViewFormatText, ViewFormatMarkdown, ViewFormatJSON:
+	default:
+		return options, fmt.Errorf("invalid --format %q: expected %q, %q or %q",
+			options.format, ViewFormatMarkdown, ViewFormatText, ViewFormatJSON)
+	}
+	options.session = session
+	return options, nil
+}
+
+// parseSessionArgs parses the arguments of a subcommand that takes one
+// session file, allowing flags before and after it, and returns the file.
+func parseSessionArgs(flags *flag.FlagSet, args []string) (string, error) {
+	flags.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return "", err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+	if len(positional) != 1 {
+		return "", errors.New("expected exactly one session file")
+	}
+	return positional[0], nil
+}
+
+// viewSession prints a saved session without touching the model or any MCP
+// server. Long transcripts go through the pager when stdout is a terminal.
+func viewSession(args []string) error {
+	options, err := parseViewArgs(args)
+	if err != nil {
+		return err
+	}
+	session, err := readSessionFile(options.session)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch options.format {
+	case ViewFormatJSON:
+		data, err := json.MarshalIndent(session, "", "  ")
+		if err != nil {
+			return err
+		}
+		text = string(data) + "\n"
+	case ViewFormatMarkdown:
+		text = sessionMarkdown(session)
+	default:
+		text = sessionText(session)
+	}
+
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		return showInPager(text)
+	}
+	_, err = fmt.Print(text)
+	return err
+}
+
+// viewRole names the speaker of messages[i], showing tool results and the
+// note before them as tool messages.
+func viewRole(messages []llm.Message, i int) string {
+	if retentionRole(messages, i) == roleTool {
+		return roleTool
+	}
+	return messages[i].Role
+}
+
+func sessionMarkdown(session sessionFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", session.Title)
+	fmt.Fprintf(&b, "_%s, %s_\n", session.Model, session.UpdatedAt.Format("2006-01-02 15:04"))
+	for i, message := range session.Messages {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", viewRole(session.Messages, i), strings.TrimSpace(message.Content))
+	}
+	return b.String()
+}
+
+func sessionText(session sessionFile) string {
+	var b strings.Builder
+	b.WriteString(systemColor.Sprintf("%s (%s, %s)\n", session.Title, session.Model, session.UpdatedAt.Format("2006-01-02 15:04")))
+	for i, message := range session.Messages {
+		role := viewRole(session.Messages, i)
+		switch role {
+		case RoleUser:
+			b.WriteString("\n" + userColor.Sprint("You:") + "\n")
+		case RoleAssistant:
+			b.WriteString("\n" + assistantColor.Sprint("LLoms:") + "\n")
+		case roleTool:
+			b.WriteString("\n" + toolColor.Sprint("Tool:") + "\n")
+		default:
+			b.WriteString("\n" + systemColor.Sprintf("%s:", role) + "\n")
+		}
+		b.WriteString(renderMarkdown(strings.TrimSpace(message.Content)) + "\n")
+	}
+	return b.String()
+}
+
+// renderMarkdown applies light terminal formatting to Markdown: headings
+// are highlighted and fenced code blocks are dimmed.
+func renderMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	inCode := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			inCode = !inCode
+			lines[i] = chunkColor.Sprint(line)
+		case inCode:
+			lines[i] = chunkColor.Sprint(line)
+		case strings.HasPrefix(trimmed, "#"):
+			lines[i] = markdownHeadingColor.Sprint(strings.TrimSpace(strings.TrimLeft(trimmed, "#")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
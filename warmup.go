@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// lastActivity is the unix time of the last turn, read by the keep-warm
+// loop to only ping while idle.
+var lastActivity atomic.Int64
+
+func markActivity() {
+	lastActivity.Store(time.Now().Unix())
+}
+
+// keepAliveValue converts keep_alive to what Ollama expects: a number of
+// seconds or a duration string.
+func keepAliveValue(keepAlive string) any {
+	if seconds, err := strconv.Atoi(keepAlive); err == nil {
+		return seconds
+	}
+	return keepAlive
+}
+
+// warmModel asks Ollama to load model into memory with an empty generate
+// request, which loads the model without producing any output.
+func warmModel(ollamaURL, model, keepAlive string) error {
+	request := map[string]any{"model": model}
+	if keepAlive != "" {
+		request["keep_alive"] = keepAliveValue(keepAlive)
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(ollamaURL+"/api/generate", "application/json; charset=utf-8", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("warm-up failed: %s", resp.Status)
+	}
+	return nil
+}
+
+// startWarmUp loads the chat model at startup and, with warm_up_interval
+// set, pings it again whenever the session has been idle that long.
+func (app *App) startWarmUp() {
+	if !app.config.WarmUp {
+		return
+	}
+	config := app.config
+	systemColor.Printf("Warming up model %s...\n", config.ChatModel)
+	if err := warmModel(config.apiURL(), config.ChatModel, config.KeepAlive); err != nil {
+		systemColor.Printf("Warning: Failed to warm up model: %v\n", err)
+	}
+	markActivity()
+
+	if config.WarmUpInterval == "" {
+		return
+	}
+	interval, _ := time.ParseDuration(config.WarmUpInterval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-app.ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(lastActivity.Load(), 0)) < interval {
+					continue
+				}
+				// Failures are ignored: the next turn reports any real problem.
+				if warmModel(config.apiURL(), config.ChatModel, config.KeepAlive) == nil {
+					markActivity()
+				}
+			}
+		}
+	}()
+}
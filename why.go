@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/parakeet-nest/parakeet/completion"
+	"github.com/parakeet-nest/parakeet/enums/option"
+	"github.com/parakeet-nest/parakeet/llm"
+)
+
+const whyPrompt = "Do not call any tool now. Explain briefly which of the available tools, if any, you would call to answer my previous message, with which arguments, and why. If you would not call a tool, explain why none fits."
+
+// explainToolDecision asks the tools model to explain its tool choice for
+// the last user message. Nothing is added to the conversation.
+func (app *App) explainToolDecision() {
+	if len(app.ollamaTools) == 0 {
+		systemColor.Println("No tools are loaded.")
+		return
+	}
+	records, err := app.conversation.GetAll()
+	if err != nil {
+		systemColor.Printf("Failed to read conversation: %v\n", err)
+		return
+	}
+	end := -1
+	for i, record := range records {
+		if record.Id == app.lastUserMsgID {
+			end = i
+		}
+	}
+	if end < 0 {
+		systemColor.Println("There is no turn to explain yet.")
+		return
+	}
+
+	history := getLastMessages(messagesOf(records[:end+1]), app.config, app.pinnedIndexes(records[:end+1]))
+	messages := append(app.buildMessages(history), app.config.RoleMap.outgoing([]llm.Message{{Role: RoleUser, Content: whyPrompt}})...)
+	systemColor.Printf("Asking %s about its tool decision...\n", app.config.ToolsModel)
+	answer, err := completion.Chat(app.config.apiURL(), llm.Query{
+		Model:    app.config.ToolsModel,
+		Messages: messages,
+		Tools:    app.ollamaTools,
+		Options: llm.SetOptions(map[string]any{
+			option.Temperature: app.config.ToolsTemperature,
+			option.NumCtx:      app.config.NumCtx,
+		}),
+	})
+	if err != nil {
+		systemColor.Printf("Failed to query the tools model: %v\n", err)
+		return
+	}
+	for _, toolCall := range answer.Message.ToolCalls {
+		toolColor.Printf("%s Would call: %s with args: %v\n", iconTool, toolCall.Function.Name, toolCall.Function.Arguments)
+	}
+	if answer.Message.Content != "" {
+		toolColor.Println(answer.Message.Content)
+	}
+}